@@ -0,0 +1,490 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gotd/td/bin"
+	"github.com/gotd/td/tg"
+
+	"tg-mcp/storage"
+)
+
+const (
+	archivePageSize = 100
+	// archiveMaxSyncPages bounds how far behind an archive may fall before
+	// it is restarted from the newest messages instead of caught up.
+	archiveMaxSyncPages = 10
+	// archiveMaxMessages bounds the messages kept per peer; the oldest are
+	// dropped beyond it and read from Telegram again when asked for.
+	archiveMaxMessages = 5000
+)
+
+type History struct {
+	Messages []tg.MessageClass
+	Users    []tg.UserClass
+	Chats    []tg.ChatClass
+	Count    int
+}
+
+type HistoryOptions struct {
+	OffsetID int
+	Limit    int
+	// Refresh re-reads the requested window from Telegram and overwrites
+	// the archived copy, picking up edits and deletions.
+	Refresh bool
+}
+
+type keyedMutex struct {
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+func (k *keyedMutex) lock(key string) func() {
+	k.mu.Lock()
+	if k.locks == nil {
+		k.locks = make(map[string]*sync.Mutex)
+	}
+	l, ok := k.locks[key]
+	if !ok {
+		l = &sync.Mutex{}
+		k.locks[key] = l
+	}
+	k.mu.Unlock()
+
+	l.Lock()
+	return l.Unlock
+}
+
+func archiveKey(p Peer) string {
+	return fmt.Sprintf("%s_%d", p.Kind, p.ID)
+}
+
+// History returns up to opts.Limit messages older than opts.OffsetID (or the
+// newest ones if it is zero), newest first. Messages are served from the
+// local archive, which is brought up to date with a single request for
+// messages newer than the highest archived ID.
+func (c *Client) History(ctx context.Context, peer Peer, opts HistoryOptions) (*History, error) {
+	api := c.API()
+	if api == nil {
+		return nil, fmt.Errorf("client is not running")
+	}
+
+	key := archiveKey(peer)
+	unlock := c.archiveLocks.lock(key)
+	defer unlock()
+
+	pa, err := c.archive.Load(key)
+	if err != nil {
+		// A damaged archive is rebuilt from scratch.
+		pa = &storage.PeerArchive{}
+	}
+
+	h := &historyCall{api: api, peer: peer}
+
+	if err := h.syncNewer(ctx, pa); err != nil {
+		return nil, err
+	}
+
+	inRange := pa.High > 0 && (opts.OffsetID == 0 || (opts.OffsetID >= pa.Low && opts.OffsetID <= pa.High+1))
+
+	switch {
+	case pa.High == 0:
+		// Empty chat, nothing to return.
+	case !inRange:
+		// The window is not adjacent to the archived range; serve it
+		// directly so the archive stays contiguous.
+		msgs, _, err := h.fetchWindow(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		h.result.Messages = msgs
+	default:
+		if opts.Refresh {
+			if err := h.refresh(ctx, pa, opts); err != nil {
+				return nil, err
+			}
+		}
+
+		window, err := h.collect(ctx, pa, opts)
+		if err != nil {
+			return nil, err
+		}
+		h.result.Messages = window
+	}
+
+	if h.result.Count > 0 && h.result.Count != pa.Count {
+		pa.Count = h.result.Count
+		h.changed = true
+	} else {
+		h.result.Count = pa.Count
+	}
+
+	if trimArchive(pa, archiveMaxMessages) {
+		h.changed = true
+	}
+
+	if h.changed {
+		if err := c.archive.Store(key, pa); err != nil {
+			return nil, fmt.Errorf("failed to store archive: %w", err)
+		}
+	}
+
+	return &h.result, nil
+}
+
+type historyCall struct {
+	api    *tg.Client
+	peer   Peer
+	result History
+	// changed is set once the archive differs from its stored copy.
+	changed bool
+}
+
+func (h *historyCall) fetch(ctx context.Context, req *tg.MessagesGetHistoryRequest) ([]tg.MessageClass, error) {
+	req.Peer = h.peer.InputPeer()
+
+	resp, err := h.api.MessagesGetHistory(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get history: %w", err)
+	}
+
+	var messages []tg.MessageClass
+	switch m := resp.(type) {
+	case *tg.MessagesMessages:
+		messages = m.Messages
+		h.result.Users = append(h.result.Users, m.Users...)
+		h.result.Chats = append(h.result.Chats, m.Chats...)
+		if req.MinID == 0 && req.OffsetID == 0 {
+			h.result.Count = len(m.Messages)
+		}
+	case *tg.MessagesMessagesSlice:
+		messages = m.Messages
+		h.result.Users = append(h.result.Users, m.Users...)
+		h.result.Chats = append(h.result.Chats, m.Chats...)
+		h.result.Count = m.Count
+	case *tg.MessagesChannelMessages:
+		messages = m.Messages
+		h.result.Users = append(h.result.Users, m.Users...)
+		h.result.Chats = append(h.result.Chats, m.Chats...)
+		h.result.Count = m.Count
+	}
+
+	result := make([]tg.MessageClass, 0, len(messages))
+	for _, msg := range messages {
+		if _, ok := msg.(*tg.MessageEmpty); !ok {
+			result = append(result, msg)
+		}
+	}
+	return result, nil
+}
+
+func (h *historyCall) syncNewer(ctx context.Context, pa *storage.PeerArchive) error {
+	if pa.High == 0 {
+		msgs, err := h.fetch(ctx, &tg.MessagesGetHistoryRequest{Limit: archivePageSize})
+		if err != nil {
+			return err
+		}
+		if len(msgs) == 0 {
+			return nil
+		}
+		h.changed = true
+		if err := replaceRange(pa, msgs, 0, math.MaxInt); err != nil {
+			return err
+		}
+		pa.Complete = len(msgs) < archivePageSize
+		return nil
+	}
+
+	var fetched []tg.MessageClass
+	offsetID := 0
+	for page := 0; ; page++ {
+		if page == archiveMaxSyncPages {
+			h.changed = true
+			*pa = storage.PeerArchive{Count: pa.Count}
+			return replaceRange(pa, fetched, 0, math.MaxInt)
+		}
+
+		msgs, err := h.fetch(ctx, &tg.MessagesGetHistoryRequest{
+			OffsetID: offsetID,
+			MinID:    pa.High,
+			Limit:    archivePageSize,
+		})
+		if err != nil {
+			return err
+		}
+		fetched = append(fetched, msgs...)
+		if len(msgs) < archivePageSize {
+			break
+		}
+		offsetID = lowestID(msgs)
+	}
+
+	if len(fetched) == 0 {
+		return nil
+	}
+	h.changed = true
+	return replaceRange(pa, fetched, pa.High+1, math.MaxInt)
+}
+
+func (h *historyCall) extendOlder(ctx context.Context, pa *storage.PeerArchive) (bool, error) {
+	msgs, err := h.fetch(ctx, &tg.MessagesGetHistoryRequest{
+		OffsetID: pa.Low,
+		Limit:    archivePageSize,
+	})
+	if err != nil {
+		return false, err
+	}
+	h.changed = true
+	if len(msgs) < archivePageSize {
+		pa.Complete = true
+	}
+	if err := replaceRange(pa, msgs, 0, pa.Low-1); err != nil {
+		return false, err
+	}
+	return len(msgs) > 0, nil
+}
+
+// fetchWindow reads up to opts.Limit messages older than opts.OffsetID from
+// Telegram, a page at a time, and reports whether it reached the start of
+// the chat.
+func (h *historyCall) fetchWindow(ctx context.Context, opts HistoryOptions) ([]tg.MessageClass, bool, error) {
+	var fetched []tg.MessageClass
+	offsetID := opts.OffsetID
+	for len(fetched) < opts.Limit {
+		limit := min(archivePageSize, opts.Limit-len(fetched))
+		msgs, err := h.fetch(ctx, &tg.MessagesGetHistoryRequest{
+			OffsetID: offsetID,
+			Limit:    limit,
+		})
+		if err != nil {
+			return nil, false, err
+		}
+		fetched = append(fetched, msgs...)
+		if len(msgs) < limit {
+			return fetched, true, nil
+		}
+		offsetID = lowestID(msgs)
+	}
+	return fetched, false, nil
+}
+
+func (h *historyCall) refresh(ctx context.Context, pa *storage.PeerArchive, opts HistoryOptions) error {
+	msgs, end, err := h.fetchWindow(ctx, opts)
+	if err != nil {
+		return err
+	}
+	if len(msgs) == 0 && !end {
+		return nil
+	}
+	h.changed = true
+
+	to := math.MaxInt
+	if opts.OffsetID != 0 {
+		to = opts.OffsetID - 1
+	}
+	// Only the fetched range is replaced, unless it reached the start of
+	// the chat.
+	from := 0
+	if end {
+		pa.Complete = true
+	} else {
+		from = lowestID(msgs)
+	}
+
+	return replaceRange(pa, msgs, from, to)
+}
+
+// collect returns the requested window from the archive, extending the
+// archive into older history as needed.
+func (h *historyCall) collect(ctx context.Context, pa *storage.PeerArchive, opts HistoryOptions) ([]tg.MessageClass, error) {
+	bound := math.MaxInt
+	if opts.OffsetID != 0 {
+		bound = opts.OffsetID
+	}
+
+	for {
+		var window []storage.ArchivedMessage
+		for _, m := range pa.Messages {
+			if m.ID < bound {
+				window = append(window, m)
+				if len(window) == opts.Limit {
+					break
+				}
+			}
+		}
+
+		if len(window) < opts.Limit && !pa.Complete {
+			more, err := h.extendOlder(ctx, pa)
+			if err != nil {
+				return nil, err
+			}
+			if more {
+				continue
+			}
+		}
+
+		result := make([]tg.MessageClass, 0, len(window))
+		for _, m := range window {
+			msg, err := decodeMessage(m)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode archived message %d: %w", m.ID, err)
+			}
+			result = append(result, msg)
+		}
+		return result, nil
+	}
+}
+
+// replaceRange drops archived messages with from <= ID <= to, stores msgs in
+// their place and widens the archived range to cover them.
+func replaceRange(pa *storage.PeerArchive, msgs []tg.MessageClass, from, to int) error {
+	kept := pa.Messages[:0]
+	for _, m := range pa.Messages {
+		if m.ID < from || m.ID > to {
+			kept = append(kept, m)
+		}
+	}
+	pa.Messages = kept
+
+	for _, msg := range msgs {
+		encoded, err := encodeMessage(msg)
+		if err != nil {
+			return err
+		}
+		pa.Messages = append(pa.Messages, encoded)
+	}
+
+	sort.Slice(pa.Messages, func(i, j int) bool {
+		return pa.Messages[i].ID > pa.Messages[j].ID
+	})
+
+	setBounds(pa)
+	return nil
+}
+
+// setBounds sets the archived range from the messages, which are sorted
+// newest first. An empty archive is synced from scratch on the next read.
+func setBounds(pa *storage.PeerArchive) {
+	if len(pa.Messages) == 0 {
+		pa.Low, pa.High = 0, 0
+		return
+	}
+	pa.High = pa.Messages[0].ID
+	pa.Low = pa.Messages[len(pa.Messages)-1].ID
+}
+
+// trimArchive drops the oldest messages beyond limit, keeping the archived
+// range contiguous. It reports whether anything was dropped.
+func trimArchive(pa *storage.PeerArchive, limit int) bool {
+	if len(pa.Messages) <= limit {
+		return false
+	}
+	pa.Messages = pa.Messages[:limit]
+	pa.Low = pa.Messages[limit-1].ID
+	pa.Complete = false
+	return true
+}
+
+func lowestID(msgs []tg.MessageClass) int {
+	low := math.MaxInt
+	for _, m := range msgs {
+		low = min(low, m.GetID())
+	}
+	return low
+}
+
+func encodeMessage(msg tg.MessageClass) (storage.ArchivedMessage, error) {
+	var b bin.Buffer
+	if err := msg.Encode(&b); err != nil {
+		return storage.ArchivedMessage{}, fmt.Errorf("failed to encode message %d: %w", msg.GetID(), err)
+	}
+	return storage.ArchivedMessage{ID: msg.GetID(), Data: b.Copy()}, nil
+}
+
+func decodeMessage(m storage.ArchivedMessage) (tg.MessageClass, error) {
+	return tg.DecodeMessage(&bin.Buffer{Buf: m.Data})
+}
+
+// archiveEdited replaces an archived message with its edited version.
+func (c *Client) archiveEdited(peer Peer, msg tg.MessageClass) {
+	key := archiveKey(peer)
+	if !c.archive.Exists(key) {
+		return
+	}
+
+	unlock := c.archiveLocks.lock(key)
+	defer unlock()
+
+	pa, err := c.archive.Load(key)
+	if err != nil {
+		return
+	}
+	for i, m := range pa.Messages {
+		if m.ID == msg.GetID() {
+			encoded, err := encodeMessage(msg)
+			if err != nil {
+				return
+			}
+			pa.Messages[i] = encoded
+			_ = c.archive.Store(key, pa)
+			return
+		}
+	}
+}
+
+// archiveDeleted removes messages from a peer's archive.
+func (c *Client) archiveDeleted(peer Peer, ids []int) {
+	key := archiveKey(peer)
+	if !c.archive.Exists(key) {
+		return
+	}
+	c.deleteArchived(key, ids)
+}
+
+// archiveDeletedAnywhere removes messages from every private chat and basic
+// group archive. Telegram does not say which chat such deletions belong to,
+// but outside channels message IDs are unique to the account.
+func (c *Client) archiveDeletedAnywhere(ids []int) {
+	keys, err := c.archive.Keys()
+	if err != nil {
+		return
+	}
+	for _, key := range keys {
+		if strings.HasPrefix(key, PeerUser+"_") || strings.HasPrefix(key, PeerChat+"_") {
+			c.deleteArchived(key, ids)
+		}
+	}
+}
+
+func (c *Client) deleteArchived(key string, ids []int) {
+	unlock := c.archiveLocks.lock(key)
+	defer unlock()
+
+	pa, err := c.archive.Load(key)
+	if err != nil {
+		return
+	}
+
+	deleted := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		deleted[id] = true
+	}
+
+	kept := pa.Messages[:0]
+	for _, m := range pa.Messages {
+		if !deleted[m.ID] {
+			kept = append(kept, m)
+		}
+	}
+	if len(kept) == len(pa.Messages) {
+		return
+	}
+	pa.Messages = kept
+	setBounds(pa)
+	_ = c.archive.Store(key, pa)
+}
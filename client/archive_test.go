@@ -0,0 +1,187 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/gotd/td/bin"
+	"github.com/gotd/td/tg"
+
+	"tg-mcp/storage"
+)
+
+func testArchive(t *testing.T, ids ...int) *storage.PeerArchive {
+	t.Helper()
+
+	pa := &storage.PeerArchive{Complete: true}
+	var msgs []tg.MessageClass
+	for _, id := range ids {
+		msgs = append(msgs, &tg.Message{ID: id, PeerID: &tg.PeerUser{UserID: 1}, Message: "m"})
+	}
+	if err := replaceRange(pa, msgs, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	return pa
+}
+
+func servedIDs(t *testing.T, pa *storage.PeerArchive) []int {
+	t.Helper()
+
+	h := &historyCall{}
+	msgs, err := h.collect(context.Background(), pa, HistoryOptions{Limit: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []int
+	for _, m := range msgs {
+		ids = append(ids, m.GetID())
+	}
+	return ids
+}
+
+// fakeHistory serves messages.getHistory for a chat holding messages 1 to
+// last, capping pages at 100 messages as Telegram does.
+type fakeHistory struct {
+	last     int
+	requests int
+}
+
+func (f *fakeHistory) Invoke(ctx context.Context, input bin.Encoder, output bin.Decoder) error {
+	req, ok := input.(*tg.MessagesGetHistoryRequest)
+	if !ok {
+		return fmt.Errorf("unexpected request %T", input)
+	}
+	f.requests++
+
+	high := f.last
+	if req.OffsetID != 0 {
+		high = min(high, req.OffsetID-1)
+	}
+	var msgs []tg.MessageClass
+	for id := high; id > req.MinID && id > 0 && len(msgs) < min(req.Limit, 100); id-- {
+		msgs = append(msgs, &tg.Message{ID: id, PeerID: &tg.PeerUser{UserID: 1}, Message: "m"})
+	}
+
+	output.(*tg.MessagesMessagesBox).Messages = &tg.MessagesMessagesSlice{Count: f.last, Messages: msgs}
+	return nil
+}
+
+func TestArchiveDeletedAnywhere(t *testing.T) {
+	c := &Client{archive: storage.NewMessageArchive(t.TempDir())}
+
+	archives := map[string]*storage.PeerArchive{
+		"user_1":    testArchive(t, 1, 2, 3),
+		"chat_2":    testArchive(t, 4, 5),
+		"channel_3": testArchive(t, 2, 5),
+	}
+	for key, pa := range archives {
+		if err := c.archive.Store(key, pa); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c.archiveDeletedAnywhere([]int{2, 5})
+
+	want := map[string][]int{
+		"user_1": {3, 1},
+		"chat_2": {4},
+		// Channel message IDs are their own sequence and are left alone.
+		"channel_3": {5, 2},
+	}
+	for key, ids := range want {
+		pa, err := c.archive.Load(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := servedIDs(t, pa); !slices.Equal(got, ids) {
+			t.Errorf("%s: served %v, want %v", key, got, ids)
+		}
+	}
+}
+
+func TestTrimArchive(t *testing.T) {
+	pa := testArchive(t, 1, 2, 3, 4, 5)
+
+	if trimArchive(pa, 5) {
+		t.Fatal("trimmed an archive within the limit")
+	}
+	if !trimArchive(pa, 3) {
+		t.Fatal("did not trim an archive over the limit")
+	}
+
+	var ids []int
+	for _, m := range pa.Messages {
+		ids = append(ids, m.ID)
+	}
+	if !slices.Equal(ids, []int{5, 4, 3}) {
+		t.Errorf("kept %v, want [5 4 3]", ids)
+	}
+	if pa.Low != 3 || pa.High != 5 || pa.Complete {
+		t.Errorf("range = %d..%d complete=%v, want 3..5 incomplete", pa.Low, pa.High, pa.Complete)
+	}
+}
+
+func TestRefreshLargeWindow(t *testing.T) {
+	ids := make([]int, 1000)
+	for i := range ids {
+		ids[i] = i + 1
+	}
+	pa := testArchive(t, ids...)
+	pa.Complete = false
+
+	server := &fakeHistory{last: 1200}
+	h := &historyCall{api: tg.NewClient(server), peer: Peer{Kind: PeerUser, ID: 1}}
+	if err := h.refresh(context.Background(), pa, HistoryOptions{OffsetID: 1001, Limit: 500}); err != nil {
+		t.Fatal(err)
+	}
+
+	if server.requests != 5 {
+		t.Errorf("made %d requests, want 5", server.requests)
+	}
+	if len(pa.Messages) != 1000 || pa.Low != 1 || pa.High != 1000 || pa.Complete {
+		t.Errorf("archive holds %d messages, range %d..%d complete=%v; want 1000, 1..1000 incomplete",
+			len(pa.Messages), pa.Low, pa.High, pa.Complete)
+	}
+}
+
+func TestRefreshReachesStart(t *testing.T) {
+	pa := testArchive(t, 1, 2, 3, 4, 5)
+	pa.Complete = false
+
+	server := &fakeHistory{last: 3}
+	h := &historyCall{api: tg.NewClient(server), peer: Peer{Kind: PeerUser, ID: 1}}
+	if err := h.refresh(context.Background(), pa, HistoryOptions{Limit: 500}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := servedIDs(t, pa); !slices.Equal(got, []int{3, 2, 1}) || !pa.Complete {
+		t.Errorf("served %v complete=%v, want [3 2 1] complete", got, pa.Complete)
+	}
+}
+
+func TestDeleteArchivedBounds(t *testing.T) {
+	c := &Client{archive: storage.NewMessageArchive(t.TempDir())}
+	if err := c.archive.Store("user_1", testArchive(t, 1, 2, 3, 4, 5)); err != nil {
+		t.Fatal(err)
+	}
+
+	c.deleteArchived("user_1", []int{1, 5})
+	pa, err := c.archive.Load("user_1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pa.Low != 2 || pa.High != 4 {
+		t.Errorf("range = %d..%d, want 2..4", pa.Low, pa.High)
+	}
+
+	c.deleteArchived("user_1", []int{2, 3, 4})
+	pa, err = c.archive.Load("user_1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pa.Low != 0 || pa.High != 0 {
+		t.Errorf("range = %d..%d, want empty", pa.Low, pa.High)
+	}
+}
@@ -0,0 +1,250 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gotd/td/telegram/auth"
+	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgerr"
+)
+
+var (
+	ErrCodeInvalid     = errors.New("the code is invalid")
+	ErrCodeExpired     = errors.New("the code has expired, request a new one")
+	ErrPhoneInvalid    = errors.New("the phone number is invalid")
+	ErrPasswordInvalid = errors.New("the password is incorrect")
+	ErrNoResend        = errors.New("no other way to deliver the code is available")
+	ErrTermsRequired   = errors.New("the terms of service must be accepted to sign up")
+)
+
+// FloodWaitError means Telegram rejected an auth call for too many
+// attempts; it can be retried after Wait.
+type FloodWaitError struct {
+	Wait time.Duration
+}
+
+func (e *FloodWaitError) Error() string {
+	return fmt.Sprintf("too many attempts, retry in %s", e.Wait)
+}
+
+// PasswordRequiredError means the account has 2FA enabled and the login
+// waits for CheckPassword.
+type PasswordRequiredError struct {
+	Hint string
+}
+
+func (e *PasswordRequiredError) Error() string {
+	if e.Hint == "" {
+		return "2FA password required"
+	}
+	return fmt.Sprintf("2FA password required (hint: %s)", e.Hint)
+}
+
+// SignUpRequiredError means no account exists for the phone number; the
+// login continues with SignUp. Terms is empty unless the user has to
+// accept Telegram's terms of service.
+type SignUpRequiredError struct {
+	Terms string
+}
+
+func (e *SignUpRequiredError) Error() string {
+	return "no account exists for this phone number, sign up first"
+}
+
+// authError turns the RPC errors of the login methods into the typed errors
+// above, wrapping anything else with action.
+func authError(action string, err error) error {
+	if d, ok := tgerr.AsFloodWait(err); ok {
+		return &FloodWaitError{Wait: d}
+	}
+
+	switch {
+	case tgerr.Is(err, "PHONE_CODE_INVALID", "PHONE_CODE_EMPTY"):
+		return ErrCodeInvalid
+	case tgerr.Is(err, "PHONE_CODE_EXPIRED"):
+		return ErrCodeExpired
+	case tgerr.Is(err, "PHONE_NUMBER_INVALID"):
+		return ErrPhoneInvalid
+	case tgerr.Is(err, "PASSWORD_HASH_INVALID"), errors.Is(err, auth.ErrPasswordInvalid):
+		return ErrPasswordInvalid
+	case tgerr.Is(err, "SEND_CODE_UNAVAILABLE"):
+		return ErrNoResend
+	}
+	return fmt.Errorf("failed to %s: %w", action, err)
+}
+
+func isPasswordNeeded(err error) bool {
+	return tgerr.Is(err, "SESSION_PASSWORD_NEEDED")
+}
+
+// passwordRequired moves the login to the password stage. The password is
+// checked right away if given; otherwise the account's hint is returned in
+// a PasswordRequiredError.
+func (c *Client) passwordRequired(ctx context.Context, password string) error {
+	c.mu.Lock()
+	c.passwordPending = true
+	c.mu.Unlock()
+
+	if password != "" {
+		return c.CheckPassword(ctx, password)
+	}
+
+	info, err := c.API().AccountGetPassword(ctx)
+	if err != nil {
+		return authError("get password hint", err)
+	}
+	return &PasswordRequiredError{Hint: info.Hint}
+}
+
+// PasswordPending reports whether a login waits for the 2FA password.
+func (c *Client) PasswordPending() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.passwordPending
+}
+
+// CheckPassword finishes a login that stopped at the 2FA stage.
+func (c *Client) CheckPassword(ctx context.Context, password string) error {
+	if !c.IsRunning() {
+		return fmt.Errorf("client is not running")
+	}
+	if !c.PasswordPending() {
+		return fmt.Errorf("no login is waiting for a password")
+	}
+	if password == "" {
+		return fmt.Errorf("password is required")
+	}
+
+	if _, err := c.client.Auth().Password(ctx, password); err != nil {
+		return authError("check password", err)
+	}
+
+	c.loggedIn()
+	return nil
+}
+
+// SentCode describes how a login code was delivered. NextType is how
+// ResendCode will deliver it, and Timeout the seconds to wait before
+// asking; both are empty when there is no other way.
+type SentCode struct {
+	CodeHash string
+	Type     tg.AuthSentCodeTypeClass
+	NextType tg.AuthCodeTypeClass
+	Timeout  int
+}
+
+func newSentCode(s *tg.AuthSentCode) *SentCode {
+	sent := &SentCode{
+		CodeHash: s.PhoneCodeHash,
+		Type:     s.Type,
+	}
+	if next, ok := s.GetNextType(); ok {
+		sent.NextType = next
+	}
+	if timeout, ok := s.GetTimeout(); ok {
+		sent.Timeout = timeout
+	}
+	return sent
+}
+
+// ResendCode asks Telegram to deliver the code from SendCode again, using
+// the next delivery type it offered.
+func (c *Client) ResendCode(ctx context.Context) (*SentCode, error) {
+	if !c.IsRunning() {
+		return nil, fmt.Errorf("client is not running")
+	}
+
+	c.mu.RLock()
+	phone := c.phone
+	codeHash := c.codeHash
+	c.mu.RUnlock()
+
+	if phone == "" || codeHash == "" {
+		return nil, fmt.Errorf("SendCode must be called first")
+	}
+
+	sentCode, err := c.API().AuthResendCode(ctx, &tg.AuthResendCodeRequest{
+		PhoneNumber:   phone,
+		PhoneCodeHash: codeHash,
+	})
+	if err != nil {
+		return nil, authError("resend code", err)
+	}
+
+	s, ok := sentCode.(*tg.AuthSentCode)
+	if !ok {
+		return nil, fmt.Errorf("unexpected response type")
+	}
+	sent := newSentCode(s)
+
+	c.mu.Lock()
+	c.codeHash = sent.CodeHash
+	c.mu.Unlock()
+
+	return sent, nil
+}
+
+func (c *Client) signUpRequired(signUp *tg.AuthAuthorizationSignUpRequired) error {
+	var terms *tg.HelpTermsOfService
+	if t, ok := signUp.GetTermsOfService(); ok {
+		terms = &t
+	}
+
+	c.mu.Lock()
+	c.signUpPending = true
+	c.terms = terms
+	c.mu.Unlock()
+
+	if terms == nil {
+		return &SignUpRequiredError{}
+	}
+	return &SignUpRequiredError{Terms: terms.Text}
+}
+
+// SignUp creates an account for the phone number after SignIn returned a
+// SignUpRequiredError. acceptTerms must be set if that error carried terms
+// of service.
+func (c *Client) SignUp(ctx context.Context, firstName, lastName string, acceptTerms bool) error {
+	if !c.IsRunning() {
+		return fmt.Errorf("client is not running")
+	}
+
+	c.mu.RLock()
+	phone := c.phone
+	codeHash := c.codeHash
+	pending := c.signUpPending
+	terms := c.terms
+	c.mu.RUnlock()
+
+	if !pending {
+		return fmt.Errorf("no login is waiting for sign up")
+	}
+	if terms != nil && !acceptTerms {
+		return ErrTermsRequired
+	}
+	if firstName == "" {
+		return fmt.Errorf("first name is required")
+	}
+
+	_, err := c.API().AuthSignUp(ctx, &tg.AuthSignUpRequest{
+		PhoneNumber:   phone,
+		PhoneCodeHash: codeHash,
+		FirstName:     firstName,
+		LastName:      lastName,
+	})
+	if err != nil {
+		return authError("sign up", err)
+	}
+
+	c.loggedIn()
+
+	if terms != nil {
+		if _, err := c.API().HelpAcceptTermsOfService(ctx, terms.ID); err != nil {
+			return fmt.Errorf("signed up, but failed to accept terms of service: %w", err)
+		}
+	}
+	return nil
+}
@@ -4,12 +4,16 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strconv"
-	"strings"
 	"sync"
 
 	"github.com/gotd/td/telegram"
+	"github.com/gotd/td/telegram/auth"
+	"github.com/gotd/td/telegram/auth/qrlogin"
 	"github.com/gotd/td/telegram/message"
+	"github.com/gotd/td/telegram/updates"
+	"github.com/gotd/td/telegram/updates/hook"
 	"github.com/gotd/td/tg"
 
 	"tg-mcp/storage"
@@ -20,20 +24,44 @@ type Client struct {
 	api     *tg.Client
 	sender  *message.Sender
 	storage *storage.FileStorage
+	peers   *Peers
+	dialogs dialogIndex
+	gaps    *updates.Manager
+	events  eventHub
+	archive *storage.MessageArchive
 	appID   int
 	appHash string
 
+	downloadDir string
+	uploadDir   string
+	botToken    string
+
+	archiveLocks keyedMutex
+
+	loginTokens chan struct{}
+
 	mu         sync.RWMutex
+	runCtx     context.Context
+	updates    *updatesRun
 	running    bool
 	authorized bool
 	phone      string
 	codeHash   string
+
+	loginToken      qrlogin.Token
+	passwordPending bool
+	signUpPending   bool
+	terms           *tg.HelpTermsOfService
 }
 
 type Config struct {
 	AppID       int
 	AppHash     string
 	SessionFile string
+	ArchiveDir  string
+	DownloadDir string
+	UploadDir   string
+	BotToken    string
 }
 
 func ConfigFromEnv() (*Config, error) {
@@ -52,27 +80,68 @@ func ConfigFromEnv() (*Config, error) {
 	}
 
 	sessionFile := os.Getenv("TG_SESSION_FILE")
+	archiveDir := os.Getenv("TG_ARCHIVE_DIR")
+	downloadDir := os.Getenv("TG_DOWNLOAD_DIR")
+	uploadDir := os.Getenv("TG_UPLOAD_DIR")
+	botToken := os.Getenv("TG_BOT_TOKEN")
 
 	return &Config{
 		AppID:       appID,
 		AppHash:     appHash,
 		SessionFile: sessionFile,
+		ArchiveDir:  archiveDir,
+		DownloadDir: downloadDir,
+		UploadDir:   uploadDir,
+		BotToken:    botToken,
 	}, nil
 }
 
 func New(cfg *Config) *Client {
 	sessionStorage := storage.NewFileStorage(cfg.SessionFile)
+	peers := NewPeers(storage.NewPeerStorage(storage.PeersPathFor(sessionStorage.Path())))
+
+	archiveDir := cfg.ArchiveDir
+	if archiveDir == "" {
+		archiveDir = storage.ArchivePathFor(sessionStorage.Path())
+	}
+
+	downloadDir := cfg.DownloadDir
+	if downloadDir == "" {
+		home, _ := os.UserHomeDir()
+		downloadDir = filepath.Join(home, "Downloads", "tg-mcp")
+	}
+
+	uploadDir := cfg.UploadDir
+	if uploadDir == "" {
+		home, _ := os.UserHomeDir()
+		uploadDir = filepath.Join(home, "tg-mcp-uploads")
+	}
+
+	c := &Client{
+		storage:     sessionStorage,
+		peers:       peers,
+		archive:     storage.NewMessageArchive(archiveDir),
+		appID:       cfg.AppID,
+		appHash:     cfg.AppHash,
+		botToken:    cfg.BotToken,
+		downloadDir: downloadDir,
+		uploadDir:   uploadDir,
+		loginTokens: make(chan struct{}, 1),
+	}
+	c.gaps = updates.New(updates.Config{
+		Handler: c.newUpdateHandler(),
+	})
 
-	tgClient := telegram.NewClient(cfg.AppID, cfg.AppHash, telegram.Options{
+	c.client = telegram.NewClient(cfg.AppID, cfg.AppHash, telegram.Options{
 		SessionStorage: sessionStorage,
+		UpdateHandler:  c.gaps,
+		Middlewares: []telegram.Middleware{
+			peers,
+			hook.UpdateHook(c.gaps.Handle),
+		},
 	})
 
-	return &Client{
-		client:  tgClient,
-		storage: sessionStorage,
-		appID:   cfg.AppID,
-		appHash: cfg.AppHash,
-	}
+	return c
 }
 
 func (c *Client) Run(ctx context.Context, f func(ctx context.Context) error) error {
@@ -80,12 +149,14 @@ func (c *Client) Run(ctx context.Context, f func(ctx context.Context) error) err
 		c.mu.Lock()
 		c.api = c.client.API()
 		c.sender = message.NewSender(c.api)
+		c.runCtx = ctx
 		c.running = true
 		c.mu.Unlock()
 
 		defer func() {
 			c.mu.Lock()
 			c.running = false
+			c.runCtx = nil
 			c.mu.Unlock()
 		}()
 
@@ -94,14 +165,41 @@ func (c *Client) Run(ctx context.Context, f func(ctx context.Context) error) err
 			return fmt.Errorf("failed to get auth status: %w", err)
 		}
 
+		if c.IsBot() {
+			if err := c.authorizeBot(ctx, status); err != nil {
+				return err
+			}
+			status.Authorized = true
+		}
+
 		c.mu.Lock()
 		c.authorized = status.Authorized
 		c.mu.Unlock()
 
+		if status.Authorized {
+			c.startUpdates()
+		}
+
 		return f(ctx)
 	})
 }
 
+// authorizeBot signs in with the bot token unless the session already
+// belongs to the bot.
+func (c *Client) authorizeBot(ctx context.Context, status *auth.Status) error {
+	if status.Authorized {
+		if !status.User.Bot {
+			return fmt.Errorf("session belongs to a user account; remove it or unset TG_BOT_TOKEN")
+		}
+		return nil
+	}
+
+	if _, err := c.client.Auth().Bot(ctx, c.botToken); err != nil {
+		return fmt.Errorf("failed to authorize bot: %w", err)
+	}
+	return nil
+}
+
 func (c *Client) API() *tg.Client {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
@@ -114,12 +212,22 @@ func (c *Client) Sender() *message.Sender {
 	return c.sender
 }
 
+func (c *Client) DownloadDir() string {
+	return c.downloadDir
+}
+
 func (c *Client) IsRunning() bool {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 	return c.running
 }
 
+// IsBot reports whether the client runs as a bot, set by TG_BOT_TOKEN.
+// Bots cannot use methods such as dialog listing, history or search.
+func (c *Client) IsBot() bool {
+	return c.botToken != ""
+}
+
 func (c *Client) IsAuthorized() bool {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
@@ -132,9 +240,11 @@ func (c *Client) GetPhone() string {
 	return c.phone
 }
 
-func (c *Client) SendCode(ctx context.Context, phone string) (string, error) {
+// SendCode sends a login code to phone and reports how it was delivered.
+// It returns nil if the session got authorized without a code.
+func (c *Client) SendCode(ctx context.Context, phone string) (*SentCode, error) {
 	if !c.IsRunning() {
-		return "", fmt.Errorf("client is not running")
+		return nil, fmt.Errorf("client is not running")
 	}
 
 	sentCode, err := c.api.AuthSendCode(ctx, &tg.AuthSendCodeRequest{
@@ -144,30 +254,35 @@ func (c *Client) SendCode(ctx context.Context, phone string) (string, error) {
 		Settings:    tg.CodeSettings{},
 	})
 	if err != nil {
-		return "", fmt.Errorf("failed to send code: %w", err)
+		return nil, authError("send code", err)
 	}
 
-	var codeHash string
+	var sent *SentCode
 	switch s := sentCode.(type) {
 	case *tg.AuthSentCode:
-		codeHash = s.PhoneCodeHash
+		sent = newSentCode(s)
 	case *tg.AuthSentCodeSuccess:
-		c.mu.Lock()
-		c.authorized = true
-		c.mu.Unlock()
-		return "", nil
+		c.loggedIn()
+		return nil, nil
 	default:
-		return "", fmt.Errorf("unexpected response type")
+		return nil, fmt.Errorf("unexpected response type")
 	}
 
 	c.mu.Lock()
 	c.phone = phone
-	c.codeHash = codeHash
+	c.codeHash = sent.CodeHash
+	c.passwordPending = false
+	c.signUpPending = false
+	c.terms = nil
 	c.mu.Unlock()
 
-	return codeHash, nil
+	return sent, nil
 }
 
+// SignIn submits the login code. If the account has 2FA enabled, password
+// is checked when given; otherwise a PasswordRequiredError is returned and
+// the login continues with CheckPassword. A number without an account
+// returns a SignUpRequiredError and continues with SignUp.
 func (c *Client) SignIn(ctx context.Context, code string, password string) error {
 	if !c.IsRunning() {
 		return fmt.Errorf("client is not running")
@@ -182,28 +297,23 @@ func (c *Client) SignIn(ctx context.Context, code string, password string) error
 		return fmt.Errorf("SendCode must be called first")
 	}
 
-	_, err := c.api.AuthSignIn(ctx, &tg.AuthSignInRequest{
+	authorization, err := c.api.AuthSignIn(ctx, &tg.AuthSignInRequest{
 		PhoneNumber:   phone,
 		PhoneCodeHash: codeHash,
 		PhoneCode:     code,
 	})
+	if isPasswordNeeded(err) {
+		return c.passwordRequired(ctx, password)
+	}
 	if err != nil {
-		if isSessionPasswordNeeded(err) {
-			if password == "" {
-				return fmt.Errorf("2FA password required - please provide password parameter")
-			}
-			_, err = c.client.Auth().Password(ctx, password)
-			if err != nil {
-				return fmt.Errorf("failed to authenticate with password: %w", err)
-			}
-		} else {
-			return fmt.Errorf("failed to sign in: %w", err)
-		}
+		return authError("sign in", err)
 	}
 
-	c.mu.Lock()
-	c.authorized = true
-	c.mu.Unlock()
+	if signUp, ok := authorization.(*tg.AuthAuthorizationSignUpRequired); ok {
+		return c.signUpRequired(signUp)
+	}
+
+	c.loggedIn()
 
 	return nil
 }
@@ -225,13 +335,6 @@ func (c *Client) CheckAuthStatus(ctx context.Context) (bool, error) {
 	return status.Authorized, nil
 }
 
-func isSessionPasswordNeeded(err error) bool {
-	if err == nil {
-		return false
-	}
-	return strings.Contains(err.Error(), "SESSION_PASSWORD_NEEDED")
-}
-
 func (c *Client) Logout(ctx context.Context) error {
 	if !c.IsRunning() {
 		return fmt.Errorf("client is not running")
@@ -246,10 +349,21 @@ func (c *Client) Logout(ctx context.Context) error {
 		return fmt.Errorf("failed to logout: %w", err)
 	}
 
+	c.stopUpdates()
+
 	if err := c.storage.Clear(); err != nil {
 		return fmt.Errorf("failed to clear session: %w", err)
 	}
 
+	if err := c.peers.Clear(); err != nil {
+		return fmt.Errorf("failed to clear peer cache: %w", err)
+	}
+	c.dialogs.reset()
+
+	if err := c.archive.Clear(); err != nil {
+		return fmt.Errorf("failed to clear message archive: %w", err)
+	}
+
 	c.mu.Lock()
 	c.authorized = false
 	c.phone = ""
@@ -0,0 +1,149 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/gotd/td/tg"
+)
+
+const dialogsPageSize = 100
+
+// dialogIndex records whether the dialog list has been walked. The walk
+// itself fills the peer cache, as every response passes through Peers.
+type dialogIndex struct {
+	mu     sync.Mutex
+	loaded bool
+}
+
+// ensureDialogs walks the main and archive folders once per process so
+// that every chat of the account can be resolved by ID or title.
+func (c *Client) ensureDialogs(ctx context.Context, api *tg.Client) error {
+	c.dialogs.mu.Lock()
+	defer c.dialogs.mu.Unlock()
+
+	if c.dialogs.loaded {
+		return nil
+	}
+
+	for _, folderID := range []int{0, 1} {
+		if err := c.walkDialogs(ctx, api, folderID); err != nil {
+			return err
+		}
+	}
+
+	c.dialogs.loaded = true
+	return nil
+}
+
+func (d *dialogIndex) reset() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.loaded = false
+}
+
+func (c *Client) walkDialogs(ctx context.Context, api *tg.Client, folderID int) error {
+	var (
+		walked     int
+		offsetDate int
+		offsetID   int
+		offsetPeer tg.InputPeerClass = &tg.InputPeerEmpty{}
+		seen                         = make(map[peerKey]bool)
+	)
+
+	for {
+		resp, err := api.MessagesGetDialogs(ctx, &tg.MessagesGetDialogsRequest{
+			FolderID:   folderID,
+			OffsetDate: offsetDate,
+			OffsetID:   offsetID,
+			OffsetPeer: offsetPeer,
+			Limit:      dialogsPageSize,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to get dialogs: %w", err)
+		}
+
+		var (
+			dialogs  []tg.DialogClass
+			messages []tg.MessageClass
+			complete bool
+		)
+		switch d := resp.(type) {
+		case *tg.MessagesDialogs:
+			dialogs, messages, complete = d.Dialogs, d.Messages, true
+		case *tg.MessagesDialogsSlice:
+			dialogs, messages = d.Dialogs, d.Messages
+			complete = walked+len(dialogs) >= d.Count
+		default:
+			return nil
+		}
+
+		dates := make(map[peerKey]map[int]int)
+		for _, msg := range messages {
+			id, peer, date, ok := messageMeta(msg)
+			if !ok {
+				continue
+			}
+			key := peerKeyOf(peer)
+			if dates[key] == nil {
+				dates[key] = make(map[int]int)
+			}
+			dates[key][id] = date
+		}
+
+		added := 0
+		var last *tg.Dialog
+		for _, dc := range dialogs {
+			d, ok := dc.(*tg.Dialog)
+			if !ok {
+				continue
+			}
+			last = d
+
+			key := peerKeyOf(d.Peer)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			added++
+		}
+		walked += added
+
+		if complete || last == nil || added == 0 {
+			return nil
+		}
+
+		key := peerKeyOf(last.Peer)
+		offsetID = last.TopMessage
+		offsetDate = dates[key][last.TopMessage]
+		if peer, ok := c.peers.Get(key.kind, key.id); ok {
+			offsetPeer = peer.InputPeer()
+		} else {
+			offsetPeer = &tg.InputPeerEmpty{}
+		}
+	}
+}
+
+func messageMeta(msg tg.MessageClass) (id int, peer tg.PeerClass, date int, ok bool) {
+	switch m := msg.(type) {
+	case *tg.Message:
+		return m.ID, m.PeerID, m.Date, true
+	case *tg.MessageService:
+		return m.ID, m.PeerID, m.Date, true
+	}
+	return 0, nil, 0, false
+}
+
+func peerKeyOf(p tg.PeerClass) peerKey {
+	switch p := p.(type) {
+	case *tg.PeerUser:
+		return peerKey{PeerUser, p.UserID}
+	case *tg.PeerChat:
+		return peerKey{PeerChat, p.ChatID}
+	case *tg.PeerChannel:
+		return peerKey{PeerChannel, p.ChannelID}
+	}
+	return peerKey{}
+}
@@ -0,0 +1,187 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/gotd/td/tg"
+)
+
+// downloadChunkSize satisfies upload.getFile constraints: it divides 1 MiB
+// and is a multiple of 4 KiB, so chunk-aligned offsets never cross a 1 MiB
+// boundary.
+const downloadChunkSize = 512 * 1024
+
+type MediaFile struct {
+	Location tg.InputFileLocationClass
+	Size     int64
+	FileName string
+	MimeType string
+}
+
+// FileOf returns the downloadable file of a message's media. Photos resolve
+// to their largest size.
+func FileOf(media tg.MessageMediaClass) (MediaFile, bool) {
+	switch m := media.(type) {
+	case *tg.MessageMediaPhoto:
+		photo, ok := m.Photo.(*tg.Photo)
+		if !ok {
+			return MediaFile{}, false
+		}
+		size, ok := LargestPhotoSize(photo)
+		if !ok {
+			return MediaFile{}, false
+		}
+		return MediaFile{
+			Location: &tg.InputPhotoFileLocation{
+				ID:            photo.ID,
+				AccessHash:    photo.AccessHash,
+				FileReference: photo.FileReference,
+				ThumbSize:     size.Type,
+			},
+			Size:     int64(size.Size),
+			MimeType: "image/jpeg",
+		}, true
+	case *tg.MessageMediaDocument:
+		doc, ok := m.Document.(*tg.Document)
+		if !ok {
+			return MediaFile{}, false
+		}
+		file := MediaFile{
+			Location: &tg.InputDocumentFileLocation{
+				ID:            doc.ID,
+				AccessHash:    doc.AccessHash,
+				FileReference: doc.FileReference,
+			},
+			Size:     doc.Size,
+			MimeType: doc.MimeType,
+		}
+		for _, attr := range doc.Attributes {
+			if a, ok := attr.(*tg.DocumentAttributeFilename); ok {
+				file.FileName = a.FileName
+			}
+		}
+		return file, true
+	}
+	return MediaFile{}, false
+}
+
+type PhotoSize struct {
+	Type string
+	W    int
+	H    int
+	Size int
+}
+
+func LargestPhotoSize(photo *tg.Photo) (PhotoSize, bool) {
+	var best PhotoSize
+	found := false
+	for _, s := range photo.Sizes {
+		var size PhotoSize
+		switch s := s.(type) {
+		case *tg.PhotoSize:
+			size = PhotoSize{Type: s.Type, W: s.W, H: s.H, Size: s.Size}
+		case *tg.PhotoSizeProgressive:
+			if len(s.Sizes) == 0 {
+				continue
+			}
+			size = PhotoSize{Type: s.Type, W: s.W, H: s.H, Size: s.Sizes[len(s.Sizes)-1]}
+		default:
+			continue
+		}
+		if !found || size.W*size.H > best.W*best.H {
+			best = size
+			found = true
+		}
+	}
+	return best, found
+}
+
+// DownloadFile saves a file to path. Data is first written to path+".part";
+// if that file exists from an interrupted attempt, the download resumes from
+// the last complete chunk.
+func (c *Client) DownloadFile(ctx context.Context, loc tg.InputFileLocationClass, path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	part := path + ".part"
+	f, err := os.OpenFile(part, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	offset := info.Size() / downloadChunkSize * downloadChunkSize
+	if err := f.Truncate(offset); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := c.download(ctx, loc, offset, -1, f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(part, path)
+}
+
+// DownloadBytes reads a whole file into memory, failing if it turns out to
+// be larger than maxSize.
+func (c *Client) DownloadBytes(ctx context.Context, loc tg.InputFileLocationClass, maxSize int64) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := c.download(ctx, loc, 0, maxSize, &buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (c *Client) download(ctx context.Context, loc tg.InputFileLocationClass, offset, maxSize int64, w io.Writer) error {
+	api := c.API()
+	if api == nil {
+		return fmt.Errorf("client is not running")
+	}
+
+	for {
+		file, err := api.UploadGetFile(ctx, &tg.UploadGetFileRequest{
+			Location: loc,
+			Offset:   offset,
+			Limit:    downloadChunkSize,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to download chunk at %d: %w", offset, err)
+		}
+
+		chunk, ok := file.(*tg.UploadFile)
+		if !ok {
+			return fmt.Errorf("unexpected response type %T", file)
+		}
+
+		if _, err := w.Write(chunk.Bytes); err != nil {
+			return err
+		}
+		offset += int64(len(chunk.Bytes))
+
+		if maxSize >= 0 && offset > maxSize {
+			return fmt.Errorf("file is larger than %d bytes", maxSize)
+		}
+		if len(chunk.Bytes) < downloadChunkSize {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package client
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gotd/td/crypto"
+	"github.com/gotd/td/tg"
+)
+
+// RandomID returns a random ID that Telegram uses to deduplicate requests
+// which create messages.
+func RandomID() (int64, error) {
+	return crypto.RandInt64(crypto.DefaultRand())
+}
+
+// GetMessages fetches messages of a peer by ID, using the channel-specific
+// method for channels and supergroups. IDs that do not exist or belong to a
+// different chat are missing from the result.
+func (c *Client) GetMessages(ctx context.Context, peer Peer, ids []int) (map[int]tg.MessageClass, error) {
+	api := c.API()
+	if api == nil {
+		return nil, fmt.Errorf("client is not running")
+	}
+
+	input := make([]tg.InputMessageClass, 0, len(ids))
+	for _, id := range ids {
+		input = append(input, &tg.InputMessageID{ID: id})
+	}
+
+	var resp tg.MessagesMessagesClass
+	var err error
+	if peer.Kind == PeerChannel {
+		resp, err = api.ChannelsGetMessages(ctx, &tg.ChannelsGetMessagesRequest{
+			Channel: &tg.InputChannel{ChannelID: peer.ID, AccessHash: peer.AccessHash},
+			ID:      input,
+		})
+	} else {
+		resp, err = api.MessagesGetMessages(ctx, input)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get messages: %w", err)
+	}
+
+	result := make(map[int]tg.MessageClass, len(ids))
+	modified, ok := resp.AsModified()
+	if !ok {
+		return result, nil
+	}
+	for _, msg := range modified.GetMessages() {
+		_, peerID, _, ok := messageMeta(msg)
+		if !ok || peerKeyOf(peerID) != (peerKey{peer.Kind, peer.ID}) {
+			continue
+		}
+		result[msg.GetID()] = msg
+	}
+	return result, nil
+}
+
+// GetMessage fetches a single message by ID.
+func (c *Client) GetMessage(ctx context.Context, peer Peer, id int) (tg.MessageClass, error) {
+	msgs, err := c.GetMessages(ctx, peer, []int{id})
+	if err != nil {
+		return nil, err
+	}
+	msg, ok := msgs[id]
+	if !ok {
+		return nil, fmt.Errorf("message %d not found", id)
+	}
+	return msg, nil
+}
+
+// DeleteMessages deletes messages of a peer and drops them from the archive.
+// Channel messages are always deleted for everyone; elsewhere revoke
+// controls whether they are also deleted for the other participants.
+func (c *Client) DeleteMessages(ctx context.Context, peer Peer, ids []int, revoke bool) error {
+	api := c.API()
+	if api == nil {
+		return fmt.Errorf("client is not running")
+	}
+
+	var err error
+	if peer.Kind == PeerChannel {
+		_, err = api.ChannelsDeleteMessages(ctx, &tg.ChannelsDeleteMessagesRequest{
+			Channel: &tg.InputChannel{ChannelID: peer.ID, AccessHash: peer.AccessHash},
+			ID:      ids,
+		})
+	} else {
+		_, err = api.MessagesDeleteMessages(ctx, &tg.MessagesDeleteMessagesRequest{
+			Revoke: revoke,
+			ID:     ids,
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	// Deletions outside channels arrive without a peer, so the archive is
+	// updated here rather than from the update stream.
+	c.archiveDeleted(peer, ids)
+	return nil
+}
+
+// Replies returns the messages of a thread, newest first: the replies to a
+// channel post in its discussion group, a reply thread in a supergroup or a
+// forum topic. Threads are read from Telegram directly, not the archive.
+func (c *Client) Replies(ctx context.Context, peer Peer, msgID int, opts HistoryOptions) (*History, error) {
+	api := c.API()
+	if api == nil {
+		return nil, fmt.Errorf("client is not running")
+	}
+
+	resp, err := api.MessagesGetReplies(ctx, &tg.MessagesGetRepliesRequest{
+		Peer:     peer.InputPeer(),
+		MsgID:    msgID,
+		OffsetID: opts.OffsetID,
+		Limit:    opts.Limit,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get replies: %w", err)
+	}
+
+	modified, ok := resp.AsModified()
+	if !ok {
+		return &History{}, nil
+	}
+	h := &History{
+		Users: modified.GetUsers(),
+		Chats: modified.GetChats(),
+		Count: len(modified.GetMessages()),
+	}
+	switch m := resp.(type) {
+	case *tg.MessagesMessagesSlice:
+		h.Count = m.Count
+	case *tg.MessagesChannelMessages:
+		h.Count = m.Count
+	}
+	for _, msg := range modified.GetMessages() {
+		if _, ok := msg.(*tg.MessageEmpty); !ok {
+			h.Messages = append(h.Messages, msg)
+		}
+	}
+	return h, nil
+}
+
+// Discussion is the start of a message's reply thread.
+type Discussion struct {
+	// Peer is the chat the thread lives in: the linked discussion group
+	// for channel posts, the chat itself otherwise.
+	Peer        Peer
+	MessageID   int
+	Message     tg.MessageClass
+	Users       []tg.UserClass
+	Chats       []tg.ChatClass
+	UnreadCount int
+}
+
+// Discussion looks up the thread started by a message. For a channel post
+// this is the automatic copy of the post in the discussion group, which is
+// what comments reply to.
+func (c *Client) Discussion(ctx context.Context, peer Peer, msgID int) (*Discussion, error) {
+	api := c.API()
+	if api == nil {
+		return nil, fmt.Errorf("client is not running")
+	}
+
+	resp, err := api.MessagesGetDiscussionMessage(ctx, &tg.MessagesGetDiscussionMessageRequest{
+		Peer:  peer.InputPeer(),
+		MsgID: msgID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get discussion: %w", err)
+	}
+
+	// Albums return all their parts; the thread hangs off the first one.
+	var root tg.MessageClass
+	for _, msg := range resp.Messages {
+		if _, ok := msg.(*tg.MessageEmpty); ok {
+			continue
+		}
+		if root == nil || msg.GetID() < root.GetID() {
+			root = msg
+		}
+	}
+	if root == nil {
+		return nil, fmt.Errorf("message %d has no discussion", msgID)
+	}
+	_, peerID, _, _ := messageMeta(root)
+	// The response's chats have been cached by the peers middleware.
+	thread, ok := c.peerFromPeerClass(peerID)
+	if !ok {
+		return nil, fmt.Errorf("discussion chat of message %d is unknown", msgID)
+	}
+
+	return &Discussion{
+		Peer:        thread,
+		MessageID:   root.GetID(),
+		Message:     root,
+		Users:       resp.Users,
+		Chats:       resp.Chats,
+		UnreadCount: resp.UnreadCount,
+	}, nil
+}
@@ -0,0 +1,308 @@
+package client
+
+import (
+	"context"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/gotd/td/bin"
+	"github.com/gotd/td/telegram"
+	"github.com/gotd/td/tg"
+
+	"tg-mcp/storage"
+)
+
+const (
+	PeerUser    = "user"
+	PeerChat    = "chat"
+	PeerChannel = "channel"
+)
+
+type Peer struct {
+	Kind       string
+	ID         int64
+	AccessHash int64
+	Usernames  []string
+	Title      string
+}
+
+func (p Peer) InputPeer() tg.InputPeerClass {
+	switch p.Kind {
+	case PeerUser:
+		return &tg.InputPeerUser{UserID: p.ID, AccessHash: p.AccessHash}
+	case PeerChat:
+		return &tg.InputPeerChat{ChatID: p.ID}
+	case PeerChannel:
+		return &tg.InputPeerChannel{ChannelID: p.ID, AccessHash: p.AccessHash}
+	default:
+		return &tg.InputPeerEmpty{}
+	}
+}
+
+type peerKey struct {
+	kind string
+	id   int64
+}
+
+// Peers caches users, chats and channels together with their access hashes.
+// It is fed from every API response passing through Handle and persisted to
+// a PeerStorage so lookups survive restarts.
+type Peers struct {
+	storage *storage.PeerStorage
+
+	mu         sync.RWMutex
+	peers      map[peerKey]*Peer
+	byUsername map[string]peerKey
+
+	saveMu sync.Mutex
+}
+
+func NewPeers(s *storage.PeerStorage) *Peers {
+	p := &Peers{
+		storage:    s,
+		peers:      make(map[peerKey]*Peer),
+		byUsername: make(map[string]peerKey),
+	}
+
+	// A missing or unreadable cache is not fatal: it is rebuilt from
+	// API responses as the client is used.
+	records, _ := s.LoadPeers()
+	for _, r := range records {
+		p.put(&Peer{
+			Kind:       r.Kind,
+			ID:         r.ID,
+			AccessHash: r.AccessHash,
+			Usernames:  r.Usernames,
+			Title:      r.Title,
+		})
+	}
+
+	return p
+}
+
+// Handle implements telegram.Middleware.
+func (p *Peers) Handle(next tg.Invoker) telegram.InvokeFunc {
+	return func(ctx context.Context, input bin.Encoder, output bin.Decoder) error {
+		if err := next.Invoke(ctx, input, output); err != nil {
+			return err
+		}
+
+		users, chats := extractEntities(output)
+		// Failing to persist the cache must not fail the request itself.
+		_ = p.Apply(users, chats)
+
+		return nil
+	}
+}
+
+type usersCarrier interface {
+	GetUsers() []tg.UserClass
+}
+
+type chatsCarrier interface {
+	GetChats() []tg.ChatClass
+}
+
+func extractEntities(v any) ([]tg.UserClass, []tg.ChatClass) {
+	var users []tg.UserClass
+	var chats []tg.ChatClass
+
+	for _, candidate := range []any{v, unbox(v)} {
+		if u, ok := candidate.(usersCarrier); ok && users == nil {
+			users = u.GetUsers()
+		}
+		if c, ok := candidate.(chatsCarrier); ok && chats == nil {
+			chats = c.GetChats()
+		}
+	}
+
+	return users, chats
+}
+
+// unbox returns the value wrapped by gotd's *Box types, which hold a single
+// interface field with the actual result.
+func unbox(v any) any {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return nil
+	}
+	rv = rv.Elem()
+	if rv.Kind() != reflect.Struct || rv.NumField() != 1 {
+		return nil
+	}
+	f := rv.Field(0)
+	if f.Kind() != reflect.Interface || f.IsNil() {
+		return nil
+	}
+	return f.Interface()
+}
+
+// Apply stores entities from an API response and persists the cache if
+// anything changed.
+func (p *Peers) Apply(users []tg.UserClass, chats []tg.ChatClass) error {
+	changed := false
+
+	p.mu.Lock()
+	for _, u := range users {
+		if user, ok := u.(*tg.User); ok && !user.Min {
+			changed = p.update(userPeer(user)) || changed
+		}
+	}
+	for _, ch := range chats {
+		switch c := ch.(type) {
+		case *tg.Chat:
+			changed = p.update(&Peer{Kind: PeerChat, ID: c.ID, Title: c.Title}) || changed
+		case *tg.ChatForbidden:
+			changed = p.update(&Peer{Kind: PeerChat, ID: c.ID, Title: c.Title}) || changed
+		case *tg.Channel:
+			if !c.Min {
+				changed = p.update(channelPeer(c)) || changed
+			}
+		case *tg.ChannelForbidden:
+			changed = p.update(&Peer{Kind: PeerChannel, ID: c.ID, AccessHash: c.AccessHash, Title: c.Title}) || changed
+		}
+	}
+	p.mu.Unlock()
+
+	if !changed {
+		return nil
+	}
+	return p.save()
+}
+
+func userPeer(u *tg.User) *Peer {
+	title := u.FirstName
+	if u.LastName != "" {
+		title += " " + u.LastName
+	}
+
+	var usernames []string
+	if u.Username != "" {
+		usernames = append(usernames, u.Username)
+	}
+	for _, un := range u.Usernames {
+		if un.Active && un.Username != u.Username {
+			usernames = append(usernames, un.Username)
+		}
+	}
+
+	return &Peer{Kind: PeerUser, ID: u.ID, AccessHash: u.AccessHash, Usernames: usernames, Title: title}
+}
+
+func channelPeer(c *tg.Channel) *Peer {
+	var usernames []string
+	if c.Username != "" {
+		usernames = append(usernames, c.Username)
+	}
+	for _, un := range c.Usernames {
+		if un.Active && un.Username != c.Username {
+			usernames = append(usernames, un.Username)
+		}
+	}
+
+	return &Peer{Kind: PeerChannel, ID: c.ID, AccessHash: c.AccessHash, Usernames: usernames, Title: c.Title}
+}
+
+// update must be called with mu held. It reports whether the cache changed.
+func (p *Peers) update(peer *Peer) bool {
+	key := peerKey{peer.Kind, peer.ID}
+	if old, ok := p.peers[key]; ok {
+		if old.AccessHash == peer.AccessHash && old.Title == peer.Title && slices.Equal(old.Usernames, peer.Usernames) {
+			return false
+		}
+		if peer.AccessHash == 0 {
+			peer.AccessHash = old.AccessHash
+		}
+		for _, un := range old.Usernames {
+			delete(p.byUsername, strings.ToLower(un))
+		}
+	}
+	p.put(peer)
+	return true
+}
+
+func (p *Peers) put(peer *Peer) {
+	key := peerKey{peer.Kind, peer.ID}
+	p.peers[key] = peer
+	for _, un := range peer.Usernames {
+		p.byUsername[strings.ToLower(un)] = key
+	}
+}
+
+func (p *Peers) save() error {
+	p.saveMu.Lock()
+	defer p.saveMu.Unlock()
+
+	p.mu.RLock()
+	records := make([]storage.PeerRecord, 0, len(p.peers))
+	for _, peer := range p.peers {
+		records = append(records, storage.PeerRecord{
+			Kind:       peer.Kind,
+			ID:         peer.ID,
+			AccessHash: peer.AccessHash,
+			Usernames:  peer.Usernames,
+			Title:      peer.Title,
+		})
+	}
+	p.mu.RUnlock()
+
+	return p.storage.StorePeers(records)
+}
+
+func (p *Peers) Get(kind string, id int64) (Peer, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	peer, ok := p.peers[peerKey{kind, id}]
+	if !ok {
+		return Peer{}, false
+	}
+	return *peer, true
+}
+
+// ByID looks up a peer by bare ID. Users are preferred over chats and
+// channels, matching how bare IDs were interpreted before the cache existed.
+func (p *Peers) ByID(id int64) (Peer, bool) {
+	for _, kind := range []string{PeerUser, PeerChat, PeerChannel} {
+		if peer, ok := p.Get(kind, id); ok {
+			return peer, true
+		}
+	}
+	return Peer{}, false
+}
+
+func (p *Peers) ByUsername(username string) (Peer, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	key, ok := p.byUsername[strings.ToLower(username)]
+	if !ok {
+		return Peer{}, false
+	}
+	return *p.peers[key], true
+}
+
+// ByTitle returns all peers whose title or full name equals title exactly.
+func (p *Peers) ByTitle(title string) []Peer {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	var result []Peer
+	for _, peer := range p.peers {
+		if peer.Title == title {
+			result = append(result, *peer)
+		}
+	}
+	return result
+}
+
+func (p *Peers) Clear() error {
+	p.mu.Lock()
+	p.peers = make(map[peerKey]*Peer)
+	p.byUsername = make(map[string]peerKey)
+	p.mu.Unlock()
+
+	return p.storage.Clear()
+}
@@ -0,0 +1,146 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gotd/td/telegram/auth/qrlogin"
+	"github.com/gotd/td/tg"
+)
+
+// ErrQRExpired is returned by WaitQRLogin when the login token expired
+// before it was accepted and a new one has been exported in its place.
+var ErrQRExpired = errors.New("QR code expired")
+
+func (c *Client) qrLogin() qrlogin.QR {
+	return qrlogin.NewQR(c.API(), c.appID, c.appHash, qrlogin.Options{
+		Migrate: c.client.MigrateTo,
+	})
+}
+
+// QRLogin exports a login token to be scanned from an authorized Telegram
+// app. An empty token means the login already completed.
+func (c *Client) QRLogin(ctx context.Context) (qrlogin.Token, error) {
+	if !c.IsRunning() {
+		return qrlogin.Token{}, fmt.Errorf("client is not running")
+	}
+
+	// Drop a signal left over from an earlier token.
+	select {
+	case <-c.loginTokens:
+	default:
+	}
+
+	token, err := c.exportLoginToken(ctx)
+	if err != nil {
+		return qrlogin.Token{}, err
+	}
+	if token.Empty() {
+		return qrlogin.Token{}, c.importLoginToken(ctx, "")
+	}
+
+	c.mu.Lock()
+	c.loginToken = token
+	c.passwordPending = false
+	c.mu.Unlock()
+
+	return token, nil
+}
+
+// WaitQRLogin blocks until the token from QRLogin is accepted and finishes
+// the login, using password if the account has 2FA enabled. If the token
+// expires first, a new one is exported and returned with ErrQRExpired.
+func (c *Client) WaitQRLogin(ctx context.Context, password string) (qrlogin.Token, error) {
+	if !c.IsRunning() {
+		return qrlogin.Token{}, fmt.Errorf("client is not running")
+	}
+
+	c.mu.RLock()
+	token := c.loginToken
+	passwordPending := c.passwordPending
+	c.mu.RUnlock()
+
+	if passwordPending {
+		return qrlogin.Token{}, c.passwordRequired(ctx, password)
+	}
+	if token.Empty() {
+		return qrlogin.Token{}, fmt.Errorf("auth_qr_login must be called first")
+	}
+
+	timer := time.NewTimer(time.Until(token.Expires()))
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return qrlogin.Token{}, fmt.Errorf("QR code was not scanned in time")
+	case <-c.loginTokens:
+	case <-timer.C:
+		// The token may have been accepted without the update reaching us.
+		next, err := c.exportLoginToken(ctx)
+		if err != nil {
+			return qrlogin.Token{}, err
+		}
+		if !next.Empty() {
+			c.mu.Lock()
+			c.loginToken = next
+			c.mu.Unlock()
+			return next, ErrQRExpired
+		}
+	}
+
+	return qrlogin.Token{}, c.importLoginToken(ctx, password)
+}
+
+// exportLoginToken exports a new token, returning an empty one when the
+// previous token was accepted, including on another DC.
+func (c *Client) exportLoginToken(ctx context.Context) (qrlogin.Token, error) {
+	token, err := c.qrLogin().Export(ctx)
+	var migrate *qrlogin.MigrationNeededError
+	if errors.As(err, &migrate) {
+		return qrlogin.Token{}, nil
+	}
+	if err != nil {
+		return qrlogin.Token{}, authError("export login token", err)
+	}
+	return token, nil
+}
+
+func (c *Client) importLoginToken(ctx context.Context, password string) error {
+	_, err := c.qrLogin().Import(ctx)
+	if isPasswordNeeded(err) {
+		c.mu.Lock()
+		c.loginToken = qrlogin.Token{}
+		c.mu.Unlock()
+
+		return c.passwordRequired(ctx, password)
+	}
+	if err != nil {
+		return authError("import login token", err)
+	}
+
+	c.loggedIn()
+	return nil
+}
+
+// loggedIn marks the client authorized and starts update processing.
+func (c *Client) loggedIn() {
+	c.mu.Lock()
+	c.authorized = true
+	c.loginToken = qrlogin.Token{}
+	c.passwordPending = false
+	c.signUpPending = false
+	c.terms = nil
+	c.mu.Unlock()
+
+	c.startUpdates()
+}
+
+func (c *Client) onLoginToken(ctx context.Context, e tg.Entities, u *tg.UpdateLoginToken) error {
+	select {
+	case c.loginTokens <- struct{}{}:
+	default:
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package client
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gotd/td/tg"
+)
+
+// readState returns the dialog of a chat, with its current read position
+// and unread count. It always asks Telegram, as both change with every
+// message.
+func (c *Client) readState(ctx context.Context, peer Peer) (*tg.Dialog, error) {
+	api := c.API()
+	if api == nil {
+		return nil, fmt.Errorf("client is not running")
+	}
+
+	resp, err := api.MessagesGetPeerDialogs(ctx, []tg.InputDialogPeerClass{
+		&tg.InputDialogPeer{Peer: peer.InputPeer()},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get dialog: %w", err)
+	}
+
+	for _, dc := range resp.Dialogs {
+		d, ok := dc.(*tg.Dialog)
+		if ok && peerKeyOf(d.Peer) == (peerKey{peer.Kind, peer.ID}) {
+			return d, nil
+		}
+	}
+	return nil, fmt.Errorf("chat is not in the dialog list")
+}
+
+// Unread returns up to limit incoming messages newer than the chat's read
+// position, newest first. Count is set to the chat's unread count, which
+// may exceed limit.
+func (c *Client) Unread(ctx context.Context, peer Peer, limit int) (*History, error) {
+	state, err := c.readState(ctx, peer)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &History{Count: state.UnreadCount}
+	if state.UnreadCount == 0 {
+		return result, nil
+	}
+
+	offsetID := 0
+	for len(result.Messages) < limit {
+		h, err := c.History(ctx, peer, HistoryOptions{OffsetID: offsetID, Limit: archivePageSize})
+		if err != nil {
+			return nil, err
+		}
+		result.Users = append(result.Users, h.Users...)
+		result.Chats = append(result.Chats, h.Chats...)
+
+		for _, msg := range h.Messages {
+			if msg.GetID() <= state.ReadInboxMaxID {
+				return result, nil
+			}
+			if !isOutgoing(msg) {
+				result.Messages = append(result.Messages, msg)
+				if len(result.Messages) == limit {
+					break
+				}
+			}
+		}
+		if len(h.Messages) < archivePageSize {
+			break
+		}
+		offsetID = h.Messages[len(h.Messages)-1].GetID()
+	}
+	return result, nil
+}
+
+// MarkRead marks the messages of a chat up to maxID as read, or all of them
+// if maxID is zero. Marking everything also clears unread mentions and
+// reactions.
+func (c *Client) MarkRead(ctx context.Context, peer Peer, maxID int) error {
+	api := c.API()
+	if api == nil {
+		return fmt.Errorf("client is not running")
+	}
+
+	var err error
+	if peer.Kind == PeerChannel {
+		_, err = api.ChannelsReadHistory(ctx, &tg.ChannelsReadHistoryRequest{
+			Channel: &tg.InputChannel{ChannelID: peer.ID, AccessHash: peer.AccessHash},
+			MaxID:   maxID,
+		})
+	} else {
+		_, err = api.MessagesReadHistory(ctx, &tg.MessagesReadHistoryRequest{
+			Peer:  peer.InputPeer(),
+			MaxID: maxID,
+		})
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read history: %w", err)
+	}
+	if maxID != 0 {
+		return nil
+	}
+
+	// Both calls work through the unread items in batches; a non-zero
+	// offset means there are more to go.
+	for {
+		affected, err := api.MessagesReadMentions(ctx, &tg.MessagesReadMentionsRequest{Peer: peer.InputPeer()})
+		if err != nil {
+			return fmt.Errorf("failed to read mentions: %w", err)
+		}
+		if affected.Offset == 0 {
+			break
+		}
+	}
+	for {
+		affected, err := api.MessagesReadReactions(ctx, &tg.MessagesReadReactionsRequest{Peer: peer.InputPeer()})
+		if err != nil {
+			return fmt.Errorf("failed to read reactions: %w", err)
+		}
+		if affected.Offset == 0 {
+			break
+		}
+	}
+	return nil
+}
+
+func isOutgoing(msg tg.MessageClass) bool {
+	switch m := msg.(type) {
+	case *tg.Message:
+		return m.Out
+	case *tg.MessageService:
+		return m.Out
+	}
+	return false
+}
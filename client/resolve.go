@@ -0,0 +1,254 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gotd/td/tg"
+)
+
+var usernameRe = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]{3,31}$`)
+
+type peerRef struct {
+	kind     string
+	id       int64
+	username string
+	invite   string
+	title    string
+}
+
+// parsePeerRef understands @username, bare numeric IDs, Bot API style IDs
+// (-100… for channels, negative for basic groups), t.me links and falls
+// back to treating the input as an exact title.
+func parsePeerRef(ref string) peerRef {
+	s := strings.TrimSpace(ref)
+
+	if link, ok := trimLinkPrefix(s); ok {
+		return parseLink(link)
+	}
+
+	if strings.HasPrefix(s, "@") {
+		return peerRef{username: strings.TrimPrefix(s, "@")}
+	}
+
+	if id, err := strconv.ParseInt(s, 10, 64); err == nil {
+		switch {
+		case strings.HasPrefix(s, "-100") && id < -1000000000000:
+			return peerRef{kind: PeerChannel, id: -id - 1000000000000}
+		case id < 0:
+			return peerRef{kind: PeerChat, id: -id}
+		default:
+			return peerRef{id: id}
+		}
+	}
+
+	if usernameRe.MatchString(s) {
+		return peerRef{username: s, title: s}
+	}
+
+	return peerRef{title: s}
+}
+
+func trimLinkPrefix(s string) (string, bool) {
+	lower := strings.ToLower(s)
+	for _, prefix := range []string{"https://", "http://"} {
+		if strings.HasPrefix(lower, prefix) {
+			s = s[len(prefix):]
+			lower = lower[len(prefix):]
+			break
+		}
+	}
+	if strings.HasPrefix(lower, "tg://resolve?domain=") {
+		return s[len("tg://resolve?domain="):], true
+	}
+	for _, host := range []string{"t.me/", "telegram.me/", "telegram.dog/"} {
+		if strings.HasPrefix(lower, host) {
+			return s[len(host):], true
+		}
+	}
+	return "", false
+}
+
+func parseLink(link string) peerRef {
+	if i := strings.IndexAny(link, "?#&"); i >= 0 {
+		link = link[:i]
+	}
+	parts := strings.Split(strings.Trim(link, "/"), "/")
+
+	switch {
+	case len(parts) == 0 || parts[0] == "":
+		return peerRef{}
+	case strings.HasPrefix(parts[0], "+"):
+		return peerRef{invite: strings.TrimPrefix(parts[0], "+")}
+	case parts[0] == "joinchat" && len(parts) > 1:
+		return peerRef{invite: parts[1]}
+	case parts[0] == "c" && len(parts) > 1:
+		if id, err := strconv.ParseInt(parts[1], 10, 64); err == nil {
+			return peerRef{kind: PeerChannel, id: id}
+		}
+		return peerRef{}
+	case parts[0] == "s" && len(parts) > 1:
+		return peerRef{username: parts[1]}
+	default:
+		return peerRef{username: parts[0]}
+	}
+}
+
+func (c *Client) Peers() *Peers {
+	return c.peers
+}
+
+// Resolve finds a peer by reference, consulting the local cache first and
+// falling back to the API only on a miss.
+func (c *Client) Resolve(ctx context.Context, ref string) (Peer, error) {
+	api := c.API()
+	if api == nil {
+		return Peer{}, fmt.Errorf("client is not running")
+	}
+
+	r := parsePeerRef(ref)
+
+	if peer, ok := c.lookup(r); ok {
+		return peer, nil
+	}
+
+	if r.invite != "" {
+		return c.resolveInvite(ctx, api, r.invite)
+	}
+
+	if r.username != "" {
+		resolved, err := api.ContactsResolveUsername(ctx, &tg.ContactsResolveUsernameRequest{
+			Username: r.username,
+		})
+		if err == nil {
+			if peer, ok := c.peerFromPeerClass(resolved.Peer); ok {
+				return peer, nil
+			}
+		} else if r.title == "" {
+			return Peer{}, fmt.Errorf("failed to resolve @%s: %w", r.username, err)
+		}
+	}
+
+	// Bots cannot list dialogs and only know the chats seen in updates.
+	if (r.id != 0 || r.title != "") && !c.IsBot() {
+		if err := c.ensureDialogs(ctx, api); err != nil {
+			return Peer{}, err
+		}
+		if peer, ok := c.lookup(peerRef{kind: r.kind, id: r.id, title: r.title}); ok {
+			return peer, nil
+		}
+	}
+
+	if r.title != "" && len(c.peers.ByTitle(r.title)) > 1 {
+		return Peer{}, fmt.Errorf("several chats are titled %q, use an ID or username instead", r.title)
+	}
+
+	if c.IsBot() {
+		return Peer{}, fmt.Errorf("chat not found: %s; bots can only reach chats by username or once they have received an update from them", ref)
+	}
+	return Peer{}, fmt.Errorf("chat not found: %s", ref)
+}
+
+func (c *Client) lookup(r peerRef) (Peer, bool) {
+	switch {
+	case r.id != 0 && r.kind != "":
+		return c.peers.Get(r.kind, r.id)
+	case r.id != 0:
+		return c.peers.ByID(r.id)
+	}
+
+	if r.username != "" {
+		if peer, ok := c.peers.ByUsername(r.username); ok {
+			return peer, true
+		}
+	}
+
+	if r.title != "" {
+		if matches := c.peers.ByTitle(r.title); len(matches) == 1 {
+			return matches[0], true
+		}
+	}
+
+	return Peer{}, false
+}
+
+func (c *Client) peerFromPeerClass(p tg.PeerClass) (Peer, bool) {
+	key := peerKeyOf(p)
+	return c.peers.Get(key.kind, key.id)
+}
+
+// PeerOf returns the cached peer for p, or a peer with only its kind and
+// ID set if it has not been seen yet.
+func (c *Client) PeerOf(p tg.PeerClass) Peer {
+	if peer, ok := c.peerFromPeerClass(p); ok {
+		return peer
+	}
+	key := peerKeyOf(p)
+	return Peer{Kind: key.kind, ID: key.id}
+}
+
+func (c *Client) resolveInvite(ctx context.Context, api *tg.Client, hash string) (Peer, error) {
+	invite, err := api.MessagesCheckChatInvite(ctx, hash)
+	if err != nil {
+		return Peer{}, fmt.Errorf("failed to check invite link: %w", err)
+	}
+
+	var chat tg.ChatClass
+	switch i := invite.(type) {
+	case *tg.ChatInviteAlready:
+		chat = i.Chat
+	case *tg.ChatInvitePeek:
+		chat = i.Chat
+	default:
+		return Peer{}, fmt.Errorf("invite link points to a chat you have not joined")
+	}
+
+	if err := c.peers.Apply(nil, []tg.ChatClass{chat}); err != nil {
+		return Peer{}, err
+	}
+
+	switch ch := chat.(type) {
+	case *tg.Chat:
+		if peer, ok := c.peers.Get(PeerChat, ch.ID); ok {
+			return peer, nil
+		}
+	case *tg.Channel:
+		if peer, ok := c.peers.Get(PeerChannel, ch.ID); ok {
+			return peer, nil
+		}
+	}
+	return Peer{}, fmt.Errorf("invite link points to an unavailable chat")
+}
+
+func (c *Client) ResolvePeer(ctx context.Context, ref string) (tg.InputPeerClass, error) {
+	peer, err := c.Resolve(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	return peer.InputPeer(), nil
+}
+
+func (c *Client) ResolveChannel(ctx context.Context, ref string) (*tg.InputChannel, error) {
+	peer, err := c.Resolve(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	if peer.Kind != PeerChannel {
+		return nil, fmt.Errorf("%s is not a channel or supergroup", ref)
+	}
+	return &tg.InputChannel{ChannelID: peer.ID, AccessHash: peer.AccessHash}, nil
+}
+
+func (c *Client) ResolveUser(ctx context.Context, ref string) (*tg.InputUser, error) {
+	peer, err := c.Resolve(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	if peer.Kind != PeerUser {
+		return nil, fmt.Errorf("%s is not a user", ref)
+	}
+	return &tg.InputUser{UserID: peer.ID, AccessHash: peer.AccessHash}, nil
+}
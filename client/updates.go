@@ -0,0 +1,182 @@
+package client
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	"github.com/gotd/td/telegram"
+	"github.com/gotd/td/telegram/updates"
+	"github.com/gotd/td/tg"
+)
+
+const (
+	EventNewMessage     = "new_message"
+	EventEditMessage    = "edit_message"
+	EventDeleteMessages = "delete_messages"
+)
+
+const eventBufferSize = 256
+
+// Event is a message-level change received from Telegram. For deletions
+// outside channels Telegram does not say which chat the IDs belong to, so
+// Peer is left empty.
+type Event struct {
+	Kind       string
+	Peer       Peer
+	Message    tg.MessageClass
+	MessageIDs []int
+	Entities   tg.Entities
+}
+
+type eventHub struct {
+	mu   sync.Mutex
+	subs map[chan Event]struct{}
+}
+
+// Subscribe registers a listener for incoming events. Slow listeners drop
+// events rather than block the update loop. The returned func unsubscribes.
+func (c *Client) Subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, eventBufferSize)
+
+	c.events.mu.Lock()
+	if c.events.subs == nil {
+		c.events.subs = make(map[chan Event]struct{})
+	}
+	c.events.subs[ch] = struct{}{}
+	c.events.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			c.events.mu.Lock()
+			delete(c.events.subs, ch)
+			c.events.mu.Unlock()
+		})
+	}
+}
+
+func (h *eventHub) publish(e Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for ch := range h.subs {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+func (c *Client) newUpdateHandler() telegram.UpdateHandler {
+	d := tg.NewUpdateDispatcher()
+
+	d.OnNewMessage(func(ctx context.Context, e tg.Entities, u *tg.UpdateNewMessage) error {
+		c.publishMessage(EventNewMessage, u.Message, e)
+		return nil
+	})
+	d.OnNewChannelMessage(func(ctx context.Context, e tg.Entities, u *tg.UpdateNewChannelMessage) error {
+		c.publishMessage(EventNewMessage, u.Message, e)
+		return nil
+	})
+	d.OnEditMessage(func(ctx context.Context, e tg.Entities, u *tg.UpdateEditMessage) error {
+		c.publishMessage(EventEditMessage, u.Message, e)
+		return nil
+	})
+	d.OnEditChannelMessage(func(ctx context.Context, e tg.Entities, u *tg.UpdateEditChannelMessage) error {
+		c.publishMessage(EventEditMessage, u.Message, e)
+		return nil
+	})
+	d.OnDeleteMessages(func(ctx context.Context, e tg.Entities, u *tg.UpdateDeleteMessages) error {
+		c.archiveDeletedAnywhere(u.Messages)
+		c.events.publish(Event{Kind: EventDeleteMessages, MessageIDs: u.Messages, Entities: e})
+		return nil
+	})
+	d.OnDeleteChannelMessages(func(ctx context.Context, e tg.Entities, u *tg.UpdateDeleteChannelMessages) error {
+		peer := c.PeerOf(&tg.PeerChannel{ChannelID: u.ChannelID})
+		c.archiveDeleted(peer, u.Messages)
+		c.events.publish(Event{Kind: EventDeleteMessages, Peer: peer, MessageIDs: u.Messages, Entities: e})
+		return nil
+	})
+	d.OnLoginToken(c.onLoginToken)
+
+	return telegram.UpdateHandlerFunc(func(ctx context.Context, u tg.UpdatesClass) error {
+		users, chats := extractEntities(u)
+		_ = c.peers.Apply(users, chats)
+		return d.Handle(ctx, u)
+	})
+}
+
+func (c *Client) publishMessage(kind string, msg tg.MessageClass, e tg.Entities) {
+	_, peerID, _, ok := messageMeta(msg)
+	if !ok {
+		return
+	}
+	peer := c.PeerOf(peerID)
+
+	if kind == EventEditMessage {
+		c.archiveEdited(peer, msg)
+	}
+
+	c.events.publish(Event{
+		Kind:       kind,
+		Peer:       peer,
+		Message:    msg,
+		MessageIDs: []int{msg.GetID()},
+		Entities:   e,
+	})
+}
+
+type updatesRun struct {
+	cancel context.CancelFunc
+}
+
+// startUpdates begins gap-aware update processing once the client is
+// running and authorized. It is a no-op if processing is already active.
+func (c *Client) startUpdates() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.runCtx == nil || c.updates != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(c.runCtx)
+	run := &updatesRun{cancel: cancel}
+	c.updates = run
+	api := c.api
+
+	go func() {
+		defer func() {
+			c.mu.Lock()
+			if c.updates == run {
+				c.updates = nil
+			}
+			c.mu.Unlock()
+			cancel()
+		}()
+
+		self, err := c.client.Self(ctx)
+		if err != nil {
+			log.Printf("updates: failed to get self: %v", err)
+			return
+		}
+
+		err = c.gaps.Run(ctx, api, self.ID, updates.AuthOptions{IsBot: c.IsBot()})
+		if err != nil && ctx.Err() == nil {
+			log.Printf("updates: %v", err)
+		}
+	}()
+}
+
+func (c *Client) stopUpdates() {
+	c.mu.Lock()
+	run := c.updates
+	c.updates = nil
+	c.mu.Unlock()
+
+	if run != nil {
+		run.cancel()
+	}
+	c.gaps.Reset()
+}
@@ -0,0 +1,59 @@
+package client
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// UploadPath checks that a local file may be sent and returns its resolved
+// path. Only files inside the upload directory are allowed, and relative
+// paths are taken from it; the session file and the server's own storage
+// are refused even if they are placed there.
+func (c *Client) UploadPath(path string) (string, error) {
+	base, err := resolvePath(c.uploadDir)
+	if err != nil {
+		return "", fmt.Errorf("upload directory %s is not available: %w", c.uploadDir, err)
+	}
+
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(base, path)
+	}
+	resolved, err := resolvePath(path)
+	if err != nil {
+		return "", err
+	}
+	if !within(base, resolved) {
+		return "", fmt.Errorf("%s is outside the upload directory %s", path, base)
+	}
+
+	for _, p := range []string{c.storage.Path(), c.peers.storage.Path(), c.archive.Path()} {
+		protected, err := resolvePath(p)
+		if err != nil {
+			protected, _ = filepath.Abs(p)
+		}
+		if within(protected, resolved) {
+			return "", fmt.Errorf("refusing to send %s: it holds this server's session or storage", path)
+		}
+	}
+	return resolved, nil
+}
+
+// resolvePath returns the absolute path of an existing file with symlinks
+// followed.
+func resolvePath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(abs)
+}
+
+// within reports whether path is base or lies under it.
+func within(base, path string) bool {
+	rel, err := filepath.Rel(base, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
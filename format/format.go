@@ -0,0 +1,115 @@
+// Package format converts between Markdown or HTML markup and Telegram
+// message entities.
+package format
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gotd/td/telegram/message/entity"
+	"github.com/gotd/td/telegram/message/styling"
+	"github.com/gotd/td/tg"
+)
+
+const (
+	ModePlain    = "plain"
+	ModeMarkdown = "markdown"
+	ModeHTML     = "html"
+)
+
+// UserResolver returns the input user for a tg://user?id= mention.
+type UserResolver func(id int64) (tg.InputUserClass, error)
+
+// Parse validates text written in the given parse mode and returns it as
+// styled text. An empty mode means plain text.
+func Parse(text, mode string, resolve UserResolver) (styling.StyledTextOption, error) {
+	var parse func(b *entity.Builder, text string, resolve UserResolver) error
+	switch mode {
+	case "", ModePlain:
+		return styling.Plain(text), nil
+	case ModeMarkdown:
+		parse = parseMarkdown
+	case ModeHTML:
+		parse = parseHTML
+	default:
+		return styling.StyledTextOption{}, fmt.Errorf("unknown parse mode %q", mode)
+	}
+
+	// Parse once up front so malformed markup is reported before anything
+	// is uploaded or sent; the builder runs it again when sending.
+	if err := parse(&entity.Builder{}, text, resolve); err != nil {
+		return styling.StyledTextOption{}, err
+	}
+
+	return styling.Custom(func(b *entity.Builder) error {
+		return parse(b, text, resolve)
+	}), nil
+}
+
+// linkFormatter turns a link target into a text URL, or into a user mention
+// for tg://user?id= links.
+func linkFormatter(target string, resolve UserResolver) (entity.Formatter, error) {
+	u, err := url.Parse(target)
+	if err != nil || target == "" {
+		return nil, fmt.Errorf("invalid link %q", target)
+	}
+
+	if u.Scheme == "tg" && u.Host == "user" {
+		id, err := strconv.ParseInt(u.Query().Get("id"), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid user link %q", target)
+		}
+		if resolve == nil {
+			return entity.MentionName(&tg.InputUser{UserID: id}), nil
+		}
+		user, err := resolve(id)
+		if err != nil {
+			return nil, err
+		}
+		return entity.MentionName(user), nil
+	}
+
+	return entity.TextURL(target), nil
+}
+
+// span is an entity whose start has been seen but not its end.
+type span struct {
+	marker string
+	start  entity.Token
+	line   int
+	// pos is the byte offset of the opening marker in the source.
+	pos int
+	// lang is the language of a pre block.
+	lang string
+}
+
+type spanStack []span
+
+func (s spanStack) find(marker string) int {
+	for i := len(s) - 1; i >= 0; i-- {
+		if s[i].marker == marker {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s spanStack) top() (span, bool) {
+	if len(s) == 0 {
+		return span{}, false
+	}
+	return s[len(s)-1], true
+}
+
+// apply closes a span, skipping entities that would cover no text.
+func apply(b *entity.Builder, start entity.Token, f entity.Formatter) {
+	if start.UTF16Length(b) > 0 {
+		start.Apply(b, f)
+	}
+}
+
+func lineOf(text string, pos int) int {
+	return strings.Count(text[:pos], "\n") + 1
+}
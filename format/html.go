@@ -0,0 +1,153 @@
+package format
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/gotd/td/telegram/message/entity"
+)
+
+// HTML supports the tags of Telegram's Bot API HTML style: b/strong,
+// i/em, u/ins, s/strike/del, code, pre (with <code class="language-x">
+// for the language), a href, tg-spoiler or span class="tg-spoiler",
+// blockquote (optionally expandable) and br. Text must escape <, > and &.
+
+var attrPattern = regexp.MustCompile(`([a-zA-Z][a-zA-Z0-9-]*)(?:\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+)))?`)
+
+var htmlFormats = map[string]func() entity.Formatter{
+	"b":      entity.Bold,
+	"strong": entity.Bold,
+	"i":      entity.Italic,
+	"em":     entity.Italic,
+	"u":      entity.Underline,
+	"ins":    entity.Underline,
+	"s":      entity.Strike,
+	"strike": entity.Strike,
+	"del":    entity.Strike,
+	"code":   entity.Code,
+}
+
+type htmlElem struct {
+	span
+	format entity.Formatter
+}
+
+func parseHTML(b *entity.Builder, text string, resolve UserResolver) error {
+	var stack []htmlElem
+
+	pos := 0
+	for pos < len(text) {
+		lt := strings.IndexByte(text[pos:], '<')
+		if lt < 0 {
+			_, _ = b.WriteString(html.UnescapeString(text[pos:]))
+			break
+		}
+		_, _ = b.WriteString(html.UnescapeString(text[pos : pos+lt]))
+		pos += lt
+
+		// A '<' that cannot start a tag is kept as text.
+		next := byte(0)
+		if pos+1 < len(text) {
+			next = text[pos+1]
+		}
+		if next != '/' && !isASCIILetter(next) {
+			_, _ = b.WriteString("<")
+			pos++
+			continue
+		}
+
+		gt := strings.IndexByte(text[pos:], '>')
+		if gt < 0 {
+			return fmt.Errorf("line %d: unterminated tag", lineOf(text, pos))
+		}
+		tag := strings.TrimSuffix(strings.TrimSpace(text[pos+1:pos+gt]), "/")
+		line := lineOf(text, pos)
+		pos += gt + 1
+
+		if name, ok := strings.CutPrefix(tag, "/"); ok {
+			name = strings.ToLower(strings.TrimSpace(name))
+			if len(stack) == 0 {
+				return fmt.Errorf("line %d: unexpected </%s>", line, name)
+			}
+			top := stack[len(stack)-1]
+			if top.marker != name {
+				return fmt.Errorf("line %d: </%s> closes <%s> opened on line %d", line, name, top.marker, top.line)
+			}
+			stack = stack[:len(stack)-1]
+
+			switch {
+			case name == "pre":
+				apply(b, top.start, entity.Pre(top.lang))
+			case top.format != nil:
+				apply(b, top.start, top.format)
+			}
+			continue
+		}
+
+		name, rawAttrs := tag, ""
+		if i := strings.IndexFunc(tag, unicode.IsSpace); i >= 0 {
+			name, rawAttrs = tag[:i], tag[i:]
+		}
+		name = strings.ToLower(name)
+		attrs := parseAttrs(rawAttrs)
+		elem := htmlElem{span: span{marker: name, start: b.Token(), line: line}}
+
+		switch name {
+		case "br":
+			_, _ = b.WriteString("\n")
+			continue
+		case "pre":
+		case "code":
+			// <pre><code class="language-x"> sets the language of the
+			// block instead of adding a separate code entity.
+			if n := len(stack); n > 0 && stack[n-1].marker == "pre" && stack[n-1].start.UTF16Length(b) == 0 {
+				stack[n-1].lang = strings.TrimPrefix(attrs["class"], "language-")
+				break
+			}
+			elem.format = entity.Code()
+		case "a":
+			f, err := linkFormatter(attrs["href"], resolve)
+			if err != nil {
+				return fmt.Errorf("line %d: %w", line, err)
+			}
+			elem.format = f
+		case "tg-spoiler":
+			elem.format = entity.Spoiler()
+		case "span":
+			if attrs["class"] != "tg-spoiler" {
+				return fmt.Errorf("line %d: unsupported <span>, only class=\"tg-spoiler\" is allowed", line)
+			}
+			elem.format = entity.Spoiler()
+		case "blockquote":
+			_, expandable := attrs["expandable"]
+			elem.format = entity.Blockquote(expandable)
+		default:
+			f, ok := htmlFormats[name]
+			if !ok {
+				return fmt.Errorf("line %d: unsupported tag <%s>", line, name)
+			}
+			elem.format = f()
+		}
+		stack = append(stack, elem)
+	}
+
+	if n := len(stack); n > 0 {
+		return fmt.Errorf("unclosed <%s> opened on line %d", stack[n-1].marker, stack[n-1].line)
+	}
+	return nil
+}
+
+func parseAttrs(s string) map[string]string {
+	attrs := make(map[string]string)
+	for _, m := range attrPattern.FindAllStringSubmatch(s, -1) {
+		attrs[strings.ToLower(m[1])] = html.UnescapeString(m[2] + m[3] + m[4])
+	}
+	return attrs
+}
+
+func isASCIILetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
@@ -0,0 +1,61 @@
+package format
+
+import (
+	"testing"
+
+	"github.com/gotd/td/telegram/message/entity"
+)
+
+func TestParseHTML(t *testing.T) {
+	runParseCases(t, parseHTML, []parseCase{
+		{
+			name:     "nested",
+			in:       "<b>bold <i>it</i></b>",
+			text:     "bold it",
+			entities: []string{"messageEntityBold 0 7", "messageEntityItalic 5 2"},
+		},
+		{
+			name:     "escapes",
+			in:       "&lt;b&gt; <b>x&amp;y</b>",
+			text:     "<b> x&y",
+			entities: []string{"messageEntityBold 4 3"},
+		},
+		{
+			name: "bare less-than",
+			in:   "1 < 2",
+			text: "1 < 2",
+		},
+		{
+			name:     "emoji offsets",
+			in:       "😀<i>z</i> <u>😀</u>",
+			text:     "😀z 😀",
+			entities: []string{"messageEntityItalic 2 1", "messageEntityUnderline 4 2"},
+		},
+		{
+			name:     "pre language",
+			in:       `<pre><code class="language-go">x</code></pre>`,
+			text:     "x",
+			entities: []string{"messageEntityPre 0 1"},
+		},
+		{
+			name:     "spoiler and quote",
+			in:       `<blockquote><span class="tg-spoiler">s</span><br>q</blockquote>`,
+			text:     "s\nq",
+			entities: []string{"messageEntityBlockquote 0 3", "messageEntitySpoiler 0 1"},
+		},
+	})
+}
+
+func TestParseHTMLErrors(t *testing.T) {
+	for _, in := range []string{
+		"<b>unclosed",
+		"<b><i>x</b></i>",
+		"<blink>x</blink>",
+		"</b>",
+	} {
+		var b entity.Builder
+		if err := parseHTML(&b, in, nil); err == nil {
+			t.Errorf("parse %q: expected an error", in)
+		}
+	}
+}
@@ -0,0 +1,358 @@
+package format
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gotd/td/telegram/message/entity"
+)
+
+// Markdown supports **bold**, *italic* or _italic_, __underline__,
+// ~~strike~~, ||spoiler||, `code`, ```lang fenced``` blocks, [text](url)
+// links and "> " blockquotes. A backslash escapes any ASCII punctuation.
+//
+// Single * and _ only count as markers next to non-space text, and _ never
+// inside a word, so snake_case and "2 * 3" are left alone. A lone * or _
+// that is never closed, or that would cross another span, is kept as text;
+// any other unclosed or crossing construct is an error.
+
+const quoteMarker = ">"
+
+// markdownMarkers are tried longest first.
+var markdownMarkers = []struct {
+	marker string
+	format func() entity.Formatter
+}{
+	{"**", entity.Bold},
+	{"__", entity.Underline},
+	{"~~", entity.Strike},
+	{"||", entity.Spoiler},
+	{"*", entity.Italic},
+	{"_", entity.Italic},
+}
+
+type markdownParser struct {
+	src     string
+	pos     int
+	b       *entity.Builder
+	resolve UserResolver
+	stack   spanStack
+	// literal holds the markers, by source offset, to be written as text.
+	literal map[int]string
+}
+
+// unmatchedError reports a lone * or _ that has no valid closing
+// counterpart.
+type unmatchedError struct {
+	pos    int
+	marker string
+	msg    string
+}
+
+func (e *unmatchedError) Error() string {
+	return e.msg
+}
+
+func parseMarkdown(b *entity.Builder, text string, resolve UserResolver) error {
+	// Each dry run finds one unmatched * or _, which the next run treats as
+	// text, until the markup parses cleanly or fails for another reason.
+	literal := make(map[int]string)
+	for {
+		dry := &markdownParser{src: text, b: &entity.Builder{}, literal: literal}
+		err := dry.parse()
+
+		var unmatched *unmatchedError
+		if !errors.As(err, &unmatched) {
+			break
+		}
+		if _, seen := literal[unmatched.pos]; seen {
+			return err
+		}
+		literal[unmatched.pos] = unmatched.marker
+	}
+
+	p := &markdownParser{src: text, b: b, resolve: resolve, literal: literal}
+	return p.parse()
+}
+
+func (p *markdownParser) parse() error {
+	lineStart := true
+	for p.pos < len(p.src) {
+		if lineStart {
+			p.quoteLine()
+			lineStart = false
+			continue
+		}
+
+		c := p.src[p.pos]
+		switch {
+		case c == '\n':
+			if p.quoteOpen() && !p.quotedAt(p.pos+1) {
+				if err := p.close(quoteMarker); err != nil {
+					return err
+				}
+			}
+			p.write("\n")
+			p.pos++
+			lineStart = true
+		case c == '\\' && p.pos+1 < len(p.src) && isASCIIPunct(p.src[p.pos+1]):
+			p.write(p.src[p.pos+1 : p.pos+2])
+			p.pos += 2
+		case p.literal[p.pos] != "":
+			marker := p.literal[p.pos]
+			p.write(marker)
+			p.pos += len(marker)
+		case c == '`':
+			if err := p.code(); err != nil {
+				return err
+			}
+		case c == '[' && p.isLink():
+			p.push("[")
+			p.pos++
+		case c == ']' && p.stack.find("[") >= 0:
+			if err := p.closeLink(); err != nil {
+				return err
+			}
+		case strings.IndexByte("*_~|", c) >= 0:
+			if err := p.marker(); err != nil {
+				return err
+			}
+		default:
+			_, size := utf8.DecodeRuneInString(p.src[p.pos:])
+			p.write(p.src[p.pos : p.pos+size])
+			p.pos += size
+		}
+	}
+
+	if p.quoteOpen() {
+		if err := p.close(quoteMarker); err != nil {
+			return err
+		}
+	}
+	if s, ok := p.stack.top(); ok {
+		return p.unmatched(s, "unclosed %s opened on line %d", s.marker, s.line)
+	}
+	return nil
+}
+
+// unmatched reports a span that cannot be closed. Only a lone * or _ may
+// fall back to text; it is too common in plain prose to reject.
+func (p *markdownParser) unmatched(s span, format string, args ...any) error {
+	if s.marker != "*" && s.marker != "_" {
+		return fmt.Errorf(format, args...)
+	}
+	return &unmatchedError{pos: s.pos, marker: s.marker, msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *markdownParser) write(s string) {
+	_, _ = p.b.WriteString(s)
+}
+
+func (p *markdownParser) push(marker string) {
+	p.stack = append(p.stack, span{
+		marker: marker,
+		start:  p.b.Token(),
+		line:   lineOf(p.src, p.pos),
+		pos:    p.pos,
+	})
+}
+
+// close ends the innermost span, which must use the given marker.
+func (p *markdownParser) close(marker string) error {
+	s, _ := p.stack.top()
+	if s.marker != marker {
+		return p.unmatched(s, "unclosed %s opened on line %d", s.marker, s.line)
+	}
+	p.stack = p.stack[:len(p.stack)-1]
+
+	if marker == quoteMarker {
+		apply(p.b, s.start, entity.Blockquote(false))
+		return nil
+	}
+	for _, m := range markdownMarkers {
+		if m.marker == marker {
+			apply(p.b, s.start, m.format())
+		}
+	}
+	return nil
+}
+
+// quoteLine consumes a leading "> " and opens a blockquote if one is not
+// already running from the previous line.
+func (p *markdownParser) quoteLine() {
+	if !p.quotedAt(p.pos) {
+		return
+	}
+	p.pos++
+	if p.pos < len(p.src) && p.src[p.pos] == ' ' {
+		p.pos++
+	}
+	if !p.quoteOpen() {
+		p.push(quoteMarker)
+		p.stack[len(p.stack)-1].pos = -1
+	}
+}
+
+func (p *markdownParser) quotedAt(pos int) bool {
+	return pos < len(p.src) && p.src[pos] == '>'
+}
+
+func (p *markdownParser) quoteOpen() bool {
+	return p.stack.find(quoteMarker) >= 0
+}
+
+// code handles `code` spans and ``` fenced blocks, whose contents are taken
+// literally.
+func (p *markdownParser) code() error {
+	n := 0
+	for p.pos+n < len(p.src) && p.src[p.pos+n] == '`' {
+		n++
+	}
+	fence := strings.Repeat("`", n)
+	start := p.pos
+
+	end := strings.Index(p.src[start+n:], fence)
+	if end < 0 {
+		kind := "code span"
+		if n >= 3 {
+			kind = "code block"
+		}
+		return fmt.Errorf("unclosed %s opened on line %d", kind, lineOf(p.src, start))
+	}
+	content := p.src[start+n : start+n+end]
+	p.pos = start + n + end + n
+
+	tok := p.b.Token()
+	if n < 3 {
+		p.write(content)
+		apply(p.b, tok, entity.Code())
+		return nil
+	}
+
+	lang := ""
+	if first, rest, ok := strings.Cut(content, "\n"); ok {
+		if !strings.ContainsAny(first, " \t") {
+			lang = first
+			content = rest
+		}
+	}
+	content = strings.TrimSuffix(content, "\n")
+
+	p.write(content)
+	apply(p.b, tok, entity.Pre(lang))
+	return nil
+}
+
+// isLink reports whether the '[' at the current position starts a
+// [text](url) link, so that other brackets are left as text.
+func (p *markdownParser) isLink() bool {
+	depth := 0
+	for i := p.pos; i < len(p.src); i++ {
+		switch p.src[i] {
+		case '\\':
+			i++
+		case '\n':
+			return false
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				rest := p.src[i+1:]
+				return strings.HasPrefix(rest, "(") && strings.IndexByte(rest, ')') > 0
+			}
+		}
+	}
+	return false
+}
+
+func (p *markdownParser) closeLink() error {
+	rest := p.src[p.pos+1:]
+	if !strings.HasPrefix(rest, "(") {
+		p.write("]")
+		p.pos++
+		return nil
+	}
+
+	s, _ := p.stack.top()
+	if s.marker != "[" {
+		return p.unmatched(s, "line %d: link closed before %s opened on line %d",
+			lineOf(p.src, p.pos), s.marker, s.line)
+	}
+
+	end := strings.IndexByte(rest, ')')
+	target := strings.TrimSpace(rest[1:end])
+	f, err := linkFormatter(target, p.resolve)
+	if err != nil {
+		return fmt.Errorf("line %d: %w", lineOf(p.src, p.pos), err)
+	}
+
+	p.stack = p.stack[:len(p.stack)-1]
+	apply(p.b, s.start, f)
+	p.pos += 1 + end + 1
+	return nil
+}
+
+// marker handles a run of emphasis characters, opening or closing a span
+// where the surrounding text allows and writing it literally otherwise.
+func (p *markdownParser) marker() error {
+	before := ' '
+	if p.pos > 0 {
+		before, _ = utf8.DecodeLastRuneInString(p.src[:p.pos])
+	}
+
+	run, misnested := "", ""
+	for _, m := range markdownMarkers {
+		if !strings.HasPrefix(p.src[p.pos:], m.marker) {
+			continue
+		}
+		after := ' '
+		if next := p.pos + len(m.marker); next < len(p.src) {
+			after, _ = utf8.DecodeRuneInString(p.src[next:])
+		}
+
+		canClose := !unicode.IsSpace(before) && !(m.marker == "_" && isWordRune(after))
+		canOpen := !unicode.IsSpace(after) && !(m.marker == "_" && isWordRune(before))
+
+		if i := p.stack.find(m.marker); i >= 0 && canClose {
+			if i == len(p.stack)-1 {
+				p.pos += len(m.marker)
+				return p.close(m.marker)
+			}
+			// Try the shorter marker, as in the end of ***bold italic***.
+			if misnested == "" {
+				misnested = m.marker
+			}
+		} else if canOpen && run == "" {
+			p.push(m.marker)
+			p.pos += len(m.marker)
+			return nil
+		}
+		if run == "" {
+			run = m.marker
+		}
+	}
+
+	if misnested != "" {
+		s, _ := p.stack.top()
+		return p.unmatched(s, "line %d: %s closed before %s opened on line %d",
+			lineOf(p.src, p.pos), misnested, s.marker, s.line)
+	}
+	if run == "" {
+		run = p.src[p.pos : p.pos+1]
+	}
+	p.write(run)
+	p.pos += len(run)
+	return nil
+}
+
+func isASCIIPunct(c byte) bool {
+	return strings.IndexByte("!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~", c) >= 0
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
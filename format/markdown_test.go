@@ -0,0 +1,169 @@
+package format
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"testing"
+
+	"github.com/gotd/td/telegram/message/entity"
+	"github.com/gotd/td/tg"
+)
+
+// describe lists entities as "type offset length", ordered by offset and
+// then outermost first, for comparison.
+func describe(entities []tg.MessageEntityClass) []string {
+	sorted := slices.Clone(entities)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if a.GetOffset() != b.GetOffset() {
+			return a.GetOffset() < b.GetOffset()
+		}
+		if a.GetLength() != b.GetLength() {
+			return a.GetLength() > b.GetLength()
+		}
+		return a.TypeName() < b.TypeName()
+	})
+
+	var out []string
+	for _, e := range sorted {
+		out = append(out, fmt.Sprintf("%s %d %d", e.TypeName(), e.GetOffset(), e.GetLength()))
+	}
+	return out
+}
+
+type parseCase struct {
+	name     string
+	in       string
+	text     string
+	entities []string
+}
+
+func runParseCases(t *testing.T, parse func(*entity.Builder, string, UserResolver) error, cases []parseCase) {
+	t.Helper()
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var b entity.Builder
+			if err := parse(&b, tc.in, nil); err != nil {
+				t.Fatalf("parse %q: %v", tc.in, err)
+			}
+			text, entities := b.Complete()
+			if text != tc.text {
+				t.Errorf("text = %q, want %q", text, tc.text)
+			}
+			if got := describe(entities); !slices.Equal(got, tc.entities) {
+				t.Errorf("entities = %v, want %v", got, tc.entities)
+			}
+		})
+	}
+}
+
+func TestParseMarkdown(t *testing.T) {
+	runParseCases(t, parseMarkdown, []parseCase{
+		{
+			name: "plain",
+			in:   "hello",
+			text: "hello",
+		},
+		{
+			name:     "nested",
+			in:       "**bold *it* b**",
+			text:     "bold it b",
+			entities: []string{"messageEntityBold 0 9", "messageEntityItalic 5 2"},
+		},
+		{
+			name:     "bold italic",
+			in:       "***both***",
+			text:     "both",
+			entities: []string{"messageEntityBold 0 4", "messageEntityItalic 0 4"},
+		},
+		{
+			name:     "emoji before entity",
+			in:       "😀 **x**",
+			text:     "😀 x",
+			entities: []string{"messageEntityBold 3 1"},
+		},
+		{
+			name:     "emoji inside entity",
+			in:       "__a😀b__ c",
+			text:     "a😀b c",
+			entities: []string{"messageEntityUnderline 0 4"},
+		},
+		{
+			name: "unmatched single star",
+			in:   "a*b",
+			text: "a*b",
+		},
+		{
+			name: "unfinished link",
+			in:   "[x](y",
+			text: "[x](y",
+		},
+		{
+			name:     "lone star crossing a span",
+			in:       "**a _b** c",
+			text:     "a _b c",
+			entities: []string{"messageEntityBold 0 4"},
+		},
+		{
+			name:     "unmatched next to matched",
+			in:       "x*y and *z*",
+			text:     "x*y and z",
+			entities: []string{"messageEntityItalic 8 1"},
+		},
+		{
+			name:     "snake case",
+			in:       "snake_case_name _it_",
+			text:     "snake_case_name it",
+			entities: []string{"messageEntityItalic 16 2"},
+		},
+		{
+			name: "escaped marker",
+			in:   `2 \* 3 \_x\_`,
+			text: "2 * 3 _x_",
+		},
+		{
+			name:     "code keeps markers",
+			in:       "`a*b` c",
+			text:     "a*b c",
+			entities: []string{"messageEntityCode 0 3"},
+		},
+		{
+			name:     "pre with language",
+			in:       "```go\nx := 1\n```",
+			text:     "x := 1",
+			entities: []string{"messageEntityPre 0 6"},
+		},
+		{
+			name:     "link",
+			in:       "see [the *site*](https://example.com)",
+			text:     "see the site",
+			entities: []string{"messageEntityTextUrl 4 8", "messageEntityItalic 8 4"},
+		},
+		{
+			name:     "quote",
+			in:       "> a\n> b\nc",
+			text:     "a\nb\nc",
+			entities: []string{"messageEntityBlockquote 0 3"},
+		},
+	})
+}
+
+func TestParseMarkdownErrors(t *testing.T) {
+	for _, in := range []string{
+		"[x](not a url%)",
+		"[x](tg://user?id=abc)",
+		"**a",
+		"~~a",
+		"||a",
+		"a`b",
+		"```go\nx",
+		"*a **b* c**",
+	} {
+		var b entity.Builder
+		if err := parseMarkdown(&b, in, nil); err == nil {
+			t.Errorf("parse %q: expected an error", in)
+		}
+	}
+}
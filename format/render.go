@@ -0,0 +1,202 @@
+package format
+
+import (
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/gotd/td/tg"
+)
+
+var (
+	markdownEscaper = strings.NewReplacer(
+		`\`, `\\`, "*", `\*`, "_", `\_`, "~", `\~`, "|", `\|`,
+		"[", `\[`, "]", `\]`, "`", "\\`",
+	)
+	htmlEscaper = strings.NewReplacer("<", "&lt;", ">", "&gt;", "&", "&amp;")
+)
+
+// Render formats text with its entities as Markdown or HTML, in the same
+// dialects Parse accepts. Entities without a markup equivalent, such as
+// plain URLs or hashtags, are left as text. Any other mode returns text
+// unchanged.
+func Render(text string, entities []tg.MessageEntityClass, mode string) string {
+	if mode != ModeMarkdown && mode != ModeHTML {
+		return text
+	}
+
+	sorted := make([]tg.MessageEntityClass, len(entities))
+	copy(sorted, entities)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].GetOffset() != sorted[j].GetOffset() {
+			return sorted[i].GetOffset() < sorted[j].GetOffset()
+		}
+		return sorted[i].GetLength() > sorted[j].GetLength()
+	})
+
+	r := renderer{mode: mode, units: utf16.Encode([]rune(text))}
+	next := 0
+	for pos := 0; ; {
+		for len(r.stack) > 0 && r.stack[len(r.stack)-1].end <= pos {
+			r.close()
+		}
+		for next < len(sorted) && sorted[next].GetOffset() <= pos {
+			r.open(sorted[next], pos)
+			next++
+		}
+		if pos >= len(r.units) {
+			break
+		}
+
+		boundary := len(r.units)
+		if next < len(sorted) {
+			boundary = min(boundary, sorted[next].GetOffset())
+		}
+		if len(r.stack) > 0 {
+			boundary = min(boundary, r.stack[len(r.stack)-1].end)
+		}
+		r.text(string(utf16.Decode(r.units[pos:boundary])))
+		pos = boundary
+	}
+	for len(r.stack) > 0 {
+		r.close()
+	}
+
+	return r.out.String()
+}
+
+type renderSpan struct {
+	entity tg.MessageEntityClass
+	end    int
+	// start is the output position where the span's content begins.
+	start    int
+	closeTag string
+}
+
+type renderer struct {
+	mode  string
+	units []uint16
+	out   strings.Builder
+	stack []renderSpan
+}
+
+func (r *renderer) open(e tg.MessageEntityClass, pos int) {
+	end := min(e.GetOffset()+e.GetLength(), len(r.units))
+	// Entities are expected to nest; clamp any that cross their parent.
+	if len(r.stack) > 0 {
+		end = min(end, r.stack[len(r.stack)-1].end)
+	}
+	if end <= pos {
+		return
+	}
+
+	content := string(utf16.Decode(r.units[pos:end]))
+	openTag, closeTag := markdownTags(e, content)
+	if r.mode == ModeHTML {
+		openTag, closeTag = htmlTags(e)
+	}
+	r.out.WriteString(openTag)
+	r.stack = append(r.stack, renderSpan{entity: e, end: end, start: r.out.Len(), closeTag: closeTag})
+}
+
+func (r *renderer) close() {
+	s := r.stack[len(r.stack)-1]
+	r.stack = r.stack[:len(r.stack)-1]
+
+	if _, ok := s.entity.(*tg.MessageEntityBlockquote); ok && r.mode == ModeMarkdown {
+		// Markdown quotes mark every line rather than wrapping the text.
+		quoted := r.out.String()
+		body := strings.ReplaceAll(quoted[s.start:], "\n", "\n> ")
+		r.out.Reset()
+		r.out.WriteString(quoted[:s.start])
+		r.out.WriteString("> ")
+		r.out.WriteString(body)
+		return
+	}
+	r.out.WriteString(s.closeTag)
+}
+
+func (r *renderer) text(s string) {
+	if r.mode == ModeHTML {
+		r.out.WriteString(htmlEscaper.Replace(s))
+		return
+	}
+	for _, open := range r.stack {
+		switch open.entity.(type) {
+		case *tg.MessageEntityCode, *tg.MessageEntityPre:
+			r.out.WriteString(s)
+			return
+		}
+	}
+	r.out.WriteString(markdownEscaper.Replace(s))
+}
+
+func markdownTags(e tg.MessageEntityClass, content string) (string, string) {
+	switch e := e.(type) {
+	case *tg.MessageEntityBold:
+		return "**", "**"
+	case *tg.MessageEntityItalic:
+		return "*", "*"
+	case *tg.MessageEntityUnderline:
+		return "__", "__"
+	case *tg.MessageEntityStrike:
+		return "~~", "~~"
+	case *tg.MessageEntitySpoiler:
+		return "||", "||"
+	case *tg.MessageEntityCode:
+		fence := codeFence(content, 1)
+		return fence, fence
+	case *tg.MessageEntityPre:
+		fence := codeFence(content, 3)
+		return fence + e.Language + "\n", "\n" + fence
+	case *tg.MessageEntityTextURL:
+		return "[", fmt.Sprintf("](%s)", e.URL)
+	case *tg.MessageEntityMentionName:
+		return "[", fmt.Sprintf("](tg://user?id=%d)", e.UserID)
+	}
+	return "", ""
+}
+
+func htmlTags(e tg.MessageEntityClass) (string, string) {
+	switch e := e.(type) {
+	case *tg.MessageEntityBold:
+		return "<b>", "</b>"
+	case *tg.MessageEntityItalic:
+		return "<i>", "</i>"
+	case *tg.MessageEntityUnderline:
+		return "<u>", "</u>"
+	case *tg.MessageEntityStrike:
+		return "<s>", "</s>"
+	case *tg.MessageEntitySpoiler:
+		return "<tg-spoiler>", "</tg-spoiler>"
+	case *tg.MessageEntityCode:
+		return "<code>", "</code>"
+	case *tg.MessageEntityPre:
+		if e.Language != "" {
+			return fmt.Sprintf(`<pre><code class="language-%s">`, html.EscapeString(e.Language)), "</code></pre>"
+		}
+		return "<pre>", "</pre>"
+	case *tg.MessageEntityTextURL:
+		return fmt.Sprintf(`<a href="%s">`, html.EscapeString(e.URL)), "</a>"
+	case *tg.MessageEntityMentionName:
+		return fmt.Sprintf(`<a href="tg://user?id=%d">`, e.UserID), "</a>"
+	case *tg.MessageEntityBlockquote:
+		if e.Collapsed {
+			return "<blockquote expandable>", "</blockquote>"
+		}
+		return "<blockquote>", "</blockquote>"
+	}
+	return "", ""
+}
+
+// codeFence returns a run of at least n backticks that does not occur in
+// content.
+func codeFence(content string, n int) string {
+	fence := strings.Repeat("`", n)
+	for strings.Contains(content, fence) {
+		fence += "`"
+	}
+	return fence
+}
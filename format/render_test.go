@@ -0,0 +1,123 @@
+package format
+
+import (
+	"testing"
+
+	"github.com/gotd/td/telegram/message/entity"
+	"github.com/gotd/td/tg"
+)
+
+func TestRender(t *testing.T) {
+	cases := []struct {
+		name     string
+		text     string
+		entities []tg.MessageEntityClass
+		mode     string
+		want     string
+	}{
+		{
+			name: "plain mode",
+			text: "a*b",
+			entities: []tg.MessageEntityClass{
+				&tg.MessageEntityBold{Offset: 0, Length: 1},
+			},
+			mode: ModePlain,
+			want: "a*b",
+		},
+		{
+			name: "markdown escapes text",
+			text: "a*b_c",
+			entities: []tg.MessageEntityClass{
+				&tg.MessageEntityBold{Offset: 4, Length: 1},
+			},
+			mode: ModeMarkdown,
+			want: `a\*b\_**c**`,
+		},
+		{
+			name: "html escapes text",
+			text: "<a & b>",
+			entities: []tg.MessageEntityClass{
+				&tg.MessageEntityBold{Offset: 0, Length: 7},
+			},
+			mode: ModeHTML,
+			want: "<b>&lt;a &amp; b&gt;</b>",
+		},
+		{
+			name: "surrogate offsets",
+			text: "😀 x 😀",
+			entities: []tg.MessageEntityClass{
+				&tg.MessageEntityItalic{Offset: 3, Length: 1},
+				&tg.MessageEntityBold{Offset: 5, Length: 2},
+			},
+			mode: ModeHTML,
+			want: "😀 <i>x</i> <b>😀</b>",
+		},
+		{
+			name: "nested",
+			text: "bold it",
+			entities: []tg.MessageEntityClass{
+				&tg.MessageEntityItalic{Offset: 5, Length: 2},
+				&tg.MessageEntityBold{Offset: 0, Length: 7},
+			},
+			mode: ModeMarkdown,
+			want: "**bold *it***",
+		},
+		{
+			name:     "escapes without entities",
+			text:     "2 * 3 < 4",
+			entities: nil,
+			mode:     ModeHTML,
+			want:     "2 * 3 &lt; 4",
+		},
+		{
+			name: "code is not escaped",
+			text: "a*b",
+			entities: []tg.MessageEntityClass{
+				&tg.MessageEntityCode{Offset: 0, Length: 3},
+			},
+			mode: ModeMarkdown,
+			want: "`a*b`",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Render(tc.text, tc.entities, tc.mode); got != tc.want {
+				t.Errorf("Render = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+// TestRoundTrip checks that rendering parsed markup gives back the input,
+// for input already written the way Render writes it.
+func TestRoundTrip(t *testing.T) {
+	cases := []struct {
+		mode  string
+		parse func(*entity.Builder, string, UserResolver) error
+		in    string
+	}{
+		{ModeMarkdown, parseMarkdown, "**bold *it* b**"},
+		{ModeMarkdown, parseMarkdown, "😀 __under__ ~~gone~~ ||hidden||"},
+		{ModeMarkdown, parseMarkdown, `2 \* 3 and snake\_case`},
+		{ModeMarkdown, parseMarkdown, "[a *link*](https://example.com) and `co*de`"},
+		{ModeMarkdown, parseMarkdown, "```go\nx := 1\n```"},
+		{ModeMarkdown, parseMarkdown, "> quoted\n> lines"},
+		{ModeHTML, parseHTML, "<b>bold <i>it</i></b> &lt;&amp;&gt;"},
+		{ModeHTML, parseHTML, "😀<u>😀</u><s>x</s><tg-spoiler>s</tg-spoiler>"},
+		{ModeHTML, parseHTML, `<a href="https://example.com">x</a> <code>c</code>`},
+		{ModeHTML, parseHTML, `<pre><code class="language-go">x</code></pre>`},
+		{ModeHTML, parseHTML, "<blockquote>q</blockquote>"},
+	}
+
+	for _, tc := range cases {
+		var b entity.Builder
+		if err := tc.parse(&b, tc.in, nil); err != nil {
+			t.Fatalf("parse %q: %v", tc.in, err)
+		}
+		text, entities := b.Complete()
+		if got := Render(text, entities, tc.mode); got != tc.in {
+			t.Errorf("%s round trip of %q gave %q", tc.mode, tc.in, got)
+		}
+	}
+}
@@ -7,6 +7,7 @@ toolchain go1.24.11
 require (
 	github.com/gotd/td v0.136.0
 	github.com/modelcontextprotocol/go-sdk v1.1.0
+	rsc.io/qr v0.2.0
 )
 
 require (
@@ -46,5 +47,4 @@ require (
 	golang.org/x/text v0.31.0 // indirect
 	golang.org/x/tools v0.39.0 // indirect
 	gopkg.in/yaml.v2 v2.4.0 // indirect
-	rsc.io/qr v0.2.0 // indirect
 )
@@ -31,11 +31,23 @@ func main() {
 
 	tools.RegisterAuthTools(server, tgClient)
 	tools.RegisterSendTools(server, tgClient)
+	tools.RegisterFilesTools(server, tgClient)
+	tools.RegisterPollsTools(server, tgClient)
+	tools.RegisterEditTools(server, tgClient)
+	tools.RegisterReactionsTools(server, tgClient)
+	tools.RegisterPinsTools(server, tgClient)
+	tools.RegisterScheduledTools(server, tgClient)
 	tools.RegisterMessagesTools(server, tgClient)
+	tools.RegisterRepliesTools(server, tgClient)
 	tools.RegisterChatsTools(server, tgClient)
 	tools.RegisterManageTools(server, tgClient)
 	tools.RegisterUsersTools(server, tgClient)
 	tools.RegisterChannelsTools(server, tgClient)
+	tools.RegisterSearchTools(server, tgClient)
+	tools.RegisterMediaTools(server, tgClient)
+	tools.RegisterTopicsTools(server, tgClient)
+	tools.RegisterUpdatesTools(server, tgClient)
+	tools.RegisterSessionsTools(server, tgClient)
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
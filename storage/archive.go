@@ -0,0 +1,128 @@
+package storage
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// ArchivedMessage is a message in its raw TL encoding, so that it can be
+// decoded back into the exact tg type it was received as.
+type ArchivedMessage struct {
+	ID   int    `json:"id"`
+	Data []byte `json:"data"`
+}
+
+// PeerArchive holds a contiguous range of a chat's history: every message
+// with Low <= ID <= High is present. Complete means Low is the very first
+// message of the chat.
+type PeerArchive struct {
+	Low      int               `json:"low"`
+	High     int               `json:"high"`
+	Complete bool              `json:"complete"`
+	Count    int               `json:"count"`
+	Messages []ArchivedMessage `json:"messages"`
+}
+
+type MessageArchive struct {
+	dir string
+	mu  sync.Mutex
+}
+
+func NewMessageArchive(dir string) *MessageArchive {
+	return &MessageArchive{dir: dir}
+}
+
+// ArchivePathFor returns the archive directory that lives next to a session
+// file.
+func ArchivePathFor(sessionPath string) string {
+	return strings.TrimSuffix(sessionPath, filepath.Ext(sessionPath)) + ".archive"
+}
+
+func (a *MessageArchive) file(key string) string {
+	return filepath.Join(a.dir, key+".json")
+}
+
+func (a *MessageArchive) Load(key string) (*PeerArchive, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	data, err := os.ReadFile(a.file(key))
+	if os.IsNotExist(err) {
+		return &PeerArchive{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var stored PeerArchive
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, err
+	}
+
+	return &stored, nil
+}
+
+func (a *MessageArchive) Exists(key string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	_, err := os.Stat(a.file(key))
+	return err == nil
+}
+
+// Keys lists the keys of the stored archives.
+func (a *MessageArchive) Keys() ([]string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	entries, err := os.ReadDir(a.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	for _, e := range entries {
+		if key, ok := strings.CutSuffix(e.Name(), ".json"); ok && !e.IsDir() {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+func (a *MessageArchive) Store(key string, archive *PeerArchive) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	jsonData, err := json.Marshal(archive)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(a.dir, 0700); err != nil {
+		return err
+	}
+
+	path := a.file(key)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, jsonData, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (a *MessageArchive) Path() string {
+	return a.dir
+}
+
+func (a *MessageArchive) Clear() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return os.RemoveAll(a.dir)
+}
@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+type PeerRecord struct {
+	Kind       string   `json:"kind"`
+	ID         int64    `json:"id"`
+	AccessHash int64    `json:"access_hash,omitempty"`
+	Usernames  []string `json:"usernames,omitempty"`
+	Title      string   `json:"title,omitempty"`
+}
+
+type PeerStorage struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewPeerStorage(path string) *PeerStorage {
+	return &PeerStorage{path: path}
+}
+
+// PeersPathFor returns the peer cache path that lives next to a session file.
+func PeersPathFor(sessionPath string) string {
+	return strings.TrimSuffix(sessionPath, filepath.Ext(sessionPath)) + ".peers.json"
+}
+
+func (s *PeerStorage) LoadPeers() ([]PeerRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var stored storedPeers
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, err
+	}
+
+	return stored.Peers, nil
+}
+
+func (s *PeerStorage) StorePeers(peers []PeerRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	jsonData, err := json.Marshal(storedPeers{Peers: peers})
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(s.path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, jsonData, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+func (s *PeerStorage) Path() string {
+	return s.path
+}
+
+func (s *PeerStorage) Clear() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := os.Remove(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+type storedPeers struct {
+	Peers []PeerRecord `json:"peers"`
+}
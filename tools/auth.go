@@ -2,8 +2,15 @@ package tools
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
 
+	"github.com/gotd/td/telegram/auth/qrlogin"
 	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"rsc.io/qr"
 
 	"tg-mcp/client"
 )
@@ -11,8 +18,10 @@ import (
 type AuthStatusInput struct{}
 
 type AuthStatusOutput struct {
-	Authorized bool   `json:"authorized"`
-	Phone      string `json:"phone,omitempty"`
+	Authorized       bool   `json:"authorized"`
+	PasswordRequired bool   `json:"password_required,omitempty"`
+	Bot              bool   `json:"bot,omitempty"`
+	Phone            string `json:"phone,omitempty"`
 }
 
 func AuthStatus(c *client.Client) func(ctx context.Context, req *mcp.CallToolRequest, input AuthStatusInput) (*mcp.CallToolResult, AuthStatusOutput, error) {
@@ -23,8 +32,10 @@ func AuthStatus(c *client.Client) func(ctx context.Context, req *mcp.CallToolReq
 		}
 
 		return nil, AuthStatusOutput{
-			Authorized: authorized,
-			Phone:      c.GetPhone(),
+			Authorized:       authorized,
+			PasswordRequired: !authorized && c.PasswordPending(),
+			Bot:              c.IsBot(),
+			Phone:            c.GetPhone(),
 		}, nil
 	}
 }
@@ -34,26 +45,70 @@ type SendCodeInput struct {
 }
 
 type SendCodeOutput struct {
-	Success  bool   `json:"success"`
-	CodeHash string `json:"code_hash"`
-	Message  string `json:"message,omitempty"`
+	Success    bool   `json:"success"`
+	CodeHash   string `json:"code_hash"`
+	Type       string `json:"type,omitempty"`
+	NextType   string `json:"next_type,omitempty"`
+	Timeout    int    `json:"timeout,omitempty"`
+	RetryAfter int    `json:"retry_after,omitempty"`
+	Message    string `json:"message,omitempty"`
+}
+
+// sentCodeOutput describes how a code was delivered. Type is one of app,
+// sms, call, flash_call, missed_call, email_code and a few rarer ones;
+// next_type is the delivery auth_resend_code would use.
+func sentCodeOutput(sent *client.SentCode, err error) SendCodeOutput {
+	if err != nil {
+		output := SendCodeOutput{
+			Success: false,
+			Message: err.Error(),
+		}
+		var flood *client.FloodWaitError
+		if errors.As(err, &flood) {
+			output.RetryAfter = int(flood.Wait.Seconds())
+		}
+		return output
+	}
+	if sent == nil {
+		return SendCodeOutput{
+			Success: true,
+			Message: "Successfully authorized",
+		}
+	}
+
+	output := SendCodeOutput{
+		Success:  true,
+		CodeHash: sent.CodeHash,
+		Type:     typeName(sent.Type.TypeName(), "auth.sentCodeType"),
+		Timeout:  sent.Timeout,
+	}
+	if sent.NextType != nil {
+		output.NextType = typeName(sent.NextType.TypeName(), "auth.codeType")
+	}
+	return output
 }
 
 func AuthSendCode(c *client.Client) func(ctx context.Context, req *mcp.CallToolRequest, input SendCodeInput) (*mcp.CallToolResult, SendCodeOutput, error) {
 	return func(ctx context.Context, req *mcp.CallToolRequest, input SendCodeInput) (*mcp.CallToolResult, SendCodeOutput, error) {
-		codeHash, err := c.SendCode(ctx, input.Phone)
-		if err != nil {
-			return nil, SendCodeOutput{
-				Success: false,
-				Message: err.Error(),
-			}, nil
+		sent, err := c.SendCode(ctx, input.Phone)
+		output := sentCodeOutput(sent, err)
+		if output.Success && sent != nil {
+			output.Message = fmt.Sprintf("Code sent to %s via %s", input.Phone, output.Type)
 		}
+		return nil, output, nil
+	}
+}
 
-		return nil, SendCodeOutput{
-			Success:  true,
-			CodeHash: codeHash,
-			Message:  "Code sent to " + input.Phone,
-		}, nil
+type ResendCodeInput struct{}
+
+func AuthResendCode(c *client.Client) func(ctx context.Context, req *mcp.CallToolRequest, input ResendCodeInput) (*mcp.CallToolResult, SendCodeOutput, error) {
+	return func(ctx context.Context, req *mcp.CallToolRequest, input ResendCodeInput) (*mcp.CallToolResult, SendCodeOutput, error) {
+		sent, err := c.ResendCode(ctx)
+		output := sentCodeOutput(sent, err)
+		if output.Success {
+			output.Message = "Code sent again via " + output.Type
+		}
+		return nil, output, nil
 	}
 }
 
@@ -64,24 +119,72 @@ type SubmitCodeInput struct {
 }
 
 type SubmitCodeOutput struct {
-	Success bool   `json:"success"`
-	Message string `json:"message,omitempty"`
+	Success      bool   `json:"success"`
+	Status       string `json:"status"`
+	PasswordHint string `json:"password_hint,omitempty"`
+	Terms        string `json:"terms_of_service,omitempty"`
+	RetryAfter   int    `json:"retry_after,omitempty"`
+	Message      string `json:"message,omitempty"`
+}
+
+// signInResult reports the outcome of a login step. Status is one of
+// authorized, password_required, sign_up_required, code_invalid,
+// code_expired, password_invalid, flood_wait or failed.
+func signInResult(err error) SubmitCodeOutput {
+	if err == nil {
+		return SubmitCodeOutput{
+			Success: true,
+			Status:  "authorized",
+			Message: "Successfully authorized",
+		}
+	}
+
+	output := SubmitCodeOutput{
+		Success: false,
+		Status:  "failed",
+		Message: err.Error(),
+	}
+
+	var password *client.PasswordRequiredError
+	var signUp *client.SignUpRequiredError
+	var flood *client.FloodWaitError
+	switch {
+	case errors.As(err, &password):
+		output.Status = "password_required"
+		output.PasswordHint = password.Hint
+		output.Message = "2FA password required, submit it with auth_submit_password"
+	case errors.As(err, &signUp):
+		output.Status = "sign_up_required"
+		output.Terms = signUp.Terms
+		output.Message = "No account exists for this phone number, create one with auth_sign_up"
+	case errors.As(err, &flood):
+		output.Status = "flood_wait"
+		output.RetryAfter = int(flood.Wait.Seconds())
+	case errors.Is(err, client.ErrCodeInvalid):
+		output.Status = "code_invalid"
+	case errors.Is(err, client.ErrCodeExpired):
+		output.Status = "code_expired"
+	case errors.Is(err, client.ErrPasswordInvalid):
+		output.Status = "password_invalid"
+	}
+	return output
 }
 
 func AuthSubmitCode(c *client.Client) func(ctx context.Context, req *mcp.CallToolRequest, input SubmitCodeInput) (*mcp.CallToolResult, SubmitCodeOutput, error) {
 	return func(ctx context.Context, req *mcp.CallToolRequest, input SubmitCodeInput) (*mcp.CallToolResult, SubmitCodeOutput, error) {
 		err := c.SignIn(ctx, input.Code, input.Password)
-		if err != nil {
-			return nil, SubmitCodeOutput{
-				Success: false,
-				Message: err.Error(),
-			}, nil
-		}
+		return nil, signInResult(err), nil
+	}
+}
 
-		return nil, SubmitCodeOutput{
-			Success: true,
-			Message: "Successfully authorized",
-		}, nil
+type SubmitPasswordInput struct {
+	Password string `json:"password"`
+}
+
+func AuthSubmitPassword(c *client.Client) func(ctx context.Context, req *mcp.CallToolRequest, input SubmitPasswordInput) (*mcp.CallToolResult, SubmitCodeOutput, error) {
+	return func(ctx context.Context, req *mcp.CallToolRequest, input SubmitPasswordInput) (*mcp.CallToolResult, SubmitCodeOutput, error) {
+		err := c.CheckPassword(ctx, input.Password)
+		return nil, signInResult(err), nil
 	}
 }
 
@@ -109,24 +212,199 @@ func AuthLogout(c *client.Client) func(ctx context.Context, req *mcp.CallToolReq
 	}
 }
 
+const (
+	defaultQRWait = 60 * time.Second
+	maxQRWait     = 5 * time.Minute
+)
+
+type QRLoginInput struct{}
+
+type QRLoginOutput struct {
+	Success      bool   `json:"success"`
+	Status       string `json:"status,omitempty"`
+	URL          string `json:"url,omitempty"`
+	Expires      string `json:"expires,omitempty"`
+	PasswordHint string `json:"password_hint,omitempty"`
+	RetryAfter   int    `json:"retry_after,omitempty"`
+	Message      string `json:"message,omitempty"`
+}
+
+func AuthQRLogin(c *client.Client) func(ctx context.Context, req *mcp.CallToolRequest, input QRLoginInput) (*mcp.CallToolResult, QRLoginOutput, error) {
+	return func(ctx context.Context, req *mcp.CallToolRequest, input QRLoginInput) (*mcp.CallToolResult, QRLoginOutput, error) {
+		if c.IsAuthorized() {
+			return nil, QRLoginOutput{
+				Success: false,
+				Message: "Already authorized",
+			}, nil
+		}
+
+		token, err := c.QRLogin(ctx)
+		if err != nil {
+			return nil, QRLoginOutput{
+				Success: false,
+				Message: err.Error(),
+			}, nil
+		}
+		if token.Empty() {
+			return nil, QRLoginOutput{
+				Success: true,
+				Message: "Successfully authorized",
+			}, nil
+		}
+
+		return qrResult(token, "Scan the QR code in Telegram under Settings > Devices > Link Desktop Device, then call auth_qr_wait")
+	}
+}
+
+type QRWaitInput struct {
+	Timeout  int    `json:"timeout,omitempty"`
+	Password string `json:"password,omitempty"`
+}
+
+func AuthQRWait(c *client.Client) func(ctx context.Context, req *mcp.CallToolRequest, input QRWaitInput) (*mcp.CallToolResult, QRLoginOutput, error) {
+	return func(ctx context.Context, req *mcp.CallToolRequest, input QRWaitInput) (*mcp.CallToolResult, QRLoginOutput, error) {
+		if c.IsAuthorized() {
+			return nil, QRLoginOutput{
+				Success: true,
+				Message: "Already authorized",
+			}, nil
+		}
+
+		timeout := defaultQRWait
+		if input.Timeout > 0 {
+			timeout = min(time.Duration(input.Timeout)*time.Second, maxQRWait)
+		}
+		waitCtx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		token, err := c.WaitQRLogin(waitCtx, input.Password)
+		if errors.Is(err, client.ErrQRExpired) {
+			return qrResult(token, "QR code expired; scan the new code, then call auth_qr_wait again")
+		}
+
+		result := signInResult(err)
+		return nil, QRLoginOutput{
+			Success:      result.Success,
+			Status:       result.Status,
+			PasswordHint: result.PasswordHint,
+			RetryAfter:   result.RetryAfter,
+			Message:      result.Message,
+		}, nil
+	}
+}
+
+// qrResult returns token as a PNG image and as text for terminals, along
+// with its login URL.
+func qrResult(token qrlogin.Token, message string) (*mcp.CallToolResult, QRLoginOutput, error) {
+	code, err := qr.Encode(token.URL(), qr.M)
+	if err != nil {
+		return nil, QRLoginOutput{
+			Success: false,
+			Message: fmt.Sprintf("Failed to encode QR code: %v", err),
+		}, nil
+	}
+
+	output := QRLoginOutput{
+		Success: true,
+		URL:     token.URL(),
+		Expires: token.Expires().Format(time.RFC3339),
+		Message: message,
+	}
+	outputJSON, err := json.Marshal(output)
+	if err != nil {
+		return nil, QRLoginOutput{}, err
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			&mcp.ImageContent{Data: code.PNG(), MIMEType: "image/png"},
+			&mcp.TextContent{Text: asciiQR(code)},
+			&mcp.TextContent{Text: string(outputJSON)},
+		},
+	}, output, nil
+}
+
+// asciiQR draws code with half-block characters, two modules per line,
+// surrounded by a quiet zone.
+func asciiQR(code *qr.Code) string {
+	const quiet = 2
+
+	var b strings.Builder
+	for y := -quiet; y < code.Size+quiet; y += 2 {
+		for x := -quiet; x < code.Size+quiet; x++ {
+			top, bottom := code.Black(x, y), code.Black(x, y+1)
+			switch {
+			case top && bottom:
+				b.WriteString("█")
+			case top:
+				b.WriteString("▀")
+			case bottom:
+				b.WriteString("▄")
+			default:
+				b.WriteString(" ")
+			}
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+type SignUpInput struct {
+	FirstName   string `json:"first_name"`
+	LastName    string `json:"last_name,omitempty"`
+	AcceptTerms bool   `json:"accept_terms,omitempty"`
+}
+
+func AuthSignUp(c *client.Client) func(ctx context.Context, req *mcp.CallToolRequest, input SignUpInput) (*mcp.CallToolResult, SubmitCodeOutput, error) {
+	return func(ctx context.Context, req *mcp.CallToolRequest, input SignUpInput) (*mcp.CallToolResult, SubmitCodeOutput, error) {
+		err := c.SignUp(ctx, input.FirstName, input.LastName, input.AcceptTerms)
+		return nil, signInResult(err), nil
+	}
+}
+
 func RegisterAuthTools(server *mcp.Server, c *client.Client) {
-	mcp.AddTool(server, &mcp.Tool{
+	addTool(server, c, &mcp.Tool{
 		Name:        "auth_status",
 		Description: "Check Telegram authorization status",
 	}, AuthStatus(c))
 
-	mcp.AddTool(server, &mcp.Tool{
+	addUserTool(server, c, &mcp.Tool{
 		Name:        "auth_send_code",
-		Description: "Send authorization code to phone number. Returns code_hash needed for auth_submit_code.",
+		Description: "Send authorization code to phone number. Returns code_hash needed for auth_submit_code, how the code was delivered (type) and, if it can be sent another way, next_type and the timeout in seconds before auth_resend_code may be used.",
 	}, AuthSendCode(c))
 
-	mcp.AddTool(server, &mcp.Tool{
+	addUserTool(server, c, &mcp.Tool{
+		Name:        "auth_resend_code",
+		Description: "Send the login code again using the next_type offered by auth_send_code, such as SMS or a phone call. Returns the new delivery type and code_hash.",
+	}, AuthResendCode(c))
+
+	addUserTool(server, c, &mcp.Tool{
 		Name:        "auth_submit_code",
-		Description: "Complete authorization by submitting the code received via Telegram. If 2FA is enabled, include the password.",
+		Description: "Complete authorization by submitting the code received via Telegram. status is authorized, or password_required with the account's password_hint when 2FA is enabled; then call auth_submit_password, or sign_up_required when the number has no account; then call auth_sign_up. Failures report code_invalid, code_expired or flood_wait with retry_after in seconds.",
 	}, AuthSubmitCode(c))
 
-	mcp.AddTool(server, &mcp.Tool{
+	addUserTool(server, c, &mcp.Tool{
+		Name:        "auth_sign_up",
+		Description: "Create a Telegram account after auth_submit_code returned sign_up_required. If terms_of_service was returned, show it to the user and set accept_terms=true once they agree.",
+	}, AuthSignUp(c))
+
+	addUserTool(server, c, &mcp.Tool{
+		Name:        "auth_submit_password",
+		Description: "Submit the 2FA password after auth_submit_code or auth_qr_wait returned password_required.",
+	}, AuthSubmitPassword(c))
+
+	addTool(server, c, &mcp.Tool{
 		Name:        "auth_logout",
 		Description: "Logout from current Telegram session and clear stored credentials",
 	}, AuthLogout(c))
+
+	addUserTool(server, c, &mcp.Tool{
+		Name:        "auth_qr_login",
+		Description: "Start login by QR code instead of a phone code. Returns the tg://login URL and the QR code as an image and as text; scan it from an authorized Telegram app, then call auth_qr_wait.",
+	}, AuthQRLogin(c))
+
+	addUserTool(server, c, &mcp.Tool{
+		Name:        "auth_qr_wait",
+		Description: "Wait for the QR code from auth_qr_login to be scanned and complete login. timeout is in seconds (default 60, max 300). If 2FA is enabled it returns password_required; finish with auth_submit_password. Returns a new QR code if the previous one expired.",
+	}, AuthQRWait(c))
 }
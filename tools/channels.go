@@ -103,7 +103,7 @@ func EditChannel(c *client.Client) func(ctx context.Context, req *mcp.CallToolRe
 			}, nil
 		}
 
-		inputChannel, err := getChannelFromDialogs(ctx, api, input.Channel)
+		inputChannel, err := c.ResolveChannel(ctx, input.Channel)
 		if err != nil {
 			return nil, EditChannelOutput{
 				Success: false,
@@ -170,7 +170,7 @@ func DeleteChannel(c *client.Client) func(ctx context.Context, req *mcp.CallTool
 			}, nil
 		}
 
-		inputChannel, err := getChannelFromDialogs(ctx, api, input.Channel)
+		inputChannel, err := c.ResolveChannel(ctx, input.Channel)
 		if err != nil {
 			return nil, DeleteChannelOutput{
 				Success: false,
@@ -220,7 +220,7 @@ func SetChannelUsername(c *client.Client) func(ctx context.Context, req *mcp.Cal
 			}, nil
 		}
 
-		inputChannel, err := getChannelFromDialogs(ctx, api, input.Channel)
+		inputChannel, err := c.ResolveChannel(ctx, input.Channel)
 		if err != nil {
 			return nil, SetChannelUsernameOutput{
 				Success: false,
@@ -273,7 +273,7 @@ func InviteToChannel(c *client.Client) func(ctx context.Context, req *mcp.CallTo
 			}, nil
 		}
 
-		inputChannel, err := getChannelFromDialogs(ctx, api, input.Channel)
+		inputChannel, err := c.ResolveChannel(ctx, input.Channel)
 		if err != nil {
 			return nil, InviteToChannelOutput{
 				Success: false,
@@ -283,20 +283,11 @@ func InviteToChannel(c *client.Client) func(ctx context.Context, req *mcp.CallTo
 
 		var inputUsers []tg.InputUserClass
 		for _, user := range input.Users {
-			resolved, err := api.ContactsResolveUsername(ctx, &tg.ContactsResolveUsernameRequest{
-				Username: user,
-			})
+			inputUser, err := c.ResolveUser(ctx, user)
 			if err != nil {
 				continue
 			}
-			for _, u := range resolved.Users {
-				if usr, ok := u.(*tg.User); ok {
-					inputUsers = append(inputUsers, &tg.InputUser{
-						UserID:     usr.ID,
-						AccessHash: usr.AccessHash,
-					})
-				}
-			}
+			inputUsers = append(inputUsers, inputUser)
 		}
 
 		if len(inputUsers) == 0 {
@@ -364,7 +355,7 @@ func GetChannelInfo(c *client.Client) func(ctx context.Context, req *mcp.CallToo
 			}, nil
 		}
 
-		inputChannel, err := getChannelFromDialogs(ctx, api, input.Channel)
+		inputChannel, err := c.ResolveChannel(ctx, input.Channel)
 		if err != nil {
 			return nil, GetChannelInfoOutput{
 				Success: false,
@@ -439,7 +430,7 @@ func ExportInviteLink(c *client.Client) func(ctx context.Context, req *mcp.CallT
 			}, nil
 		}
 
-		peer, err := getPeerFromDialogs(ctx, api, input.Channel)
+		peer, err := c.ResolvePeer(ctx, input.Channel)
 		if err != nil {
 			return nil, ExportInviteLinkOutput{
 				Success: false,
@@ -510,7 +501,7 @@ func GetChannelMembers(c *client.Client) func(ctx context.Context, req *mcp.Call
 			}, nil
 		}
 
-		inputChannel, err := getChannelFromDialogs(ctx, api, input.Channel)
+		inputChannel, err := c.ResolveChannel(ctx, input.Channel)
 		if err != nil {
 			return nil, GetChannelMembersOutput{
 				Success: false,
@@ -631,42 +622,42 @@ func GetChannelMembers(c *client.Client) func(ctx context.Context, req *mcp.Call
 }
 
 func RegisterChannelsTools(server *mcp.Server, c *client.Client) {
-	mcp.AddTool(server, &mcp.Tool{
+	addUserTool(server, c, &mcp.Tool{
 		Name:        "create_channel",
 		Description: "Create a new channel or supergroup. Set broadcast=true for channel, false for group.",
 	}, CreateChannel(c))
 
-	mcp.AddTool(server, &mcp.Tool{
+	addTool(server, c, &mcp.Tool{
 		Name:        "edit_channel",
 		Description: "Edit channel/group title or description",
 	}, EditChannel(c))
 
-	mcp.AddTool(server, &mcp.Tool{
+	addUserTool(server, c, &mcp.Tool{
 		Name:        "delete_channel",
 		Description: "Delete a channel or supergroup (irreversible)",
 	}, DeleteChannel(c))
 
-	mcp.AddTool(server, &mcp.Tool{
+	addUserTool(server, c, &mcp.Tool{
 		Name:        "set_channel_username",
 		Description: "Set or change channel public username",
 	}, SetChannelUsername(c))
 
-	mcp.AddTool(server, &mcp.Tool{
+	addUserTool(server, c, &mcp.Tool{
 		Name:        "invite_to_channel",
 		Description: "Invite users to a channel or group by their usernames",
 	}, InviteToChannel(c))
 
-	mcp.AddTool(server, &mcp.Tool{
+	addTool(server, c, &mcp.Tool{
 		Name:        "get_channel_info",
 		Description: "Get detailed information about a channel or group",
 	}, GetChannelInfo(c))
 
-	mcp.AddTool(server, &mcp.Tool{
+	addTool(server, c, &mcp.Tool{
 		Name:        "export_invite_link",
 		Description: "Export/create invite link for a channel or group",
 	}, ExportInviteLink(c))
 
-	mcp.AddTool(server, &mcp.Tool{
+	addTool(server, c, &mcp.Tool{
 		Name:        "get_channel_members",
 		Description: "Get channel/group members. Filter: admins, bots, banned, restricted (default: recent). Supports pagination with offset/limit.",
 	}, GetChannelMembers(c))
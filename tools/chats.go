@@ -160,8 +160,10 @@ type ChatOverview struct {
 }
 
 type GetChatsOverviewInput struct {
-	ChatsLimit    int `json:"chats_limit,omitempty"`
-	MessagesLimit int `json:"messages_limit,omitempty"`
+	ChatsLimit    int    `json:"chats_limit,omitempty"`
+	MessagesLimit int    `json:"messages_limit,omitempty"`
+	UnreadOnly    bool   `json:"unread_only,omitempty"`
+	Format        string `json:"format,omitempty"`
 }
 
 type GetChatsOverviewOutput struct {
@@ -187,6 +189,13 @@ func GetChatsOverview(c *client.Client) func(ctx context.Context, req *mcp.CallT
 			}, nil
 		}
 
+		if !validTextFormat(input.Format) {
+			return nil, GetChatsOverviewOutput{
+				Success: false,
+				Message: fmt.Sprintf("Unknown format: %s", input.Format),
+			}, nil
+		}
+
 		chatsLimit := input.ChatsLimit
 		if chatsLimit <= 0 {
 			chatsLimit = 20
@@ -203,10 +212,7 @@ func GetChatsOverview(c *client.Client) func(ctx context.Context, req *mcp.CallT
 			messagesLimit = 10
 		}
 
-		dialogs, err := api.MessagesGetDialogs(ctx, &tg.MessagesGetDialogsRequest{
-			OffsetPeer: &tg.InputPeerEmpty{},
-			Limit:      chatsLimit,
-		})
+		dialogList, chats, users, err := overviewDialogs(ctx, c, api, chatsLimit, input.UnreadOnly)
 		if err != nil {
 			return nil, GetChatsOverviewOutput{
 				Success: false,
@@ -214,8 +220,6 @@ func GetChatsOverview(c *client.Client) func(ctx context.Context, req *mcp.CallT
 			}, nil
 		}
 
-		dialogList, chats, users := extractDialogsData(dialogs)
-
 		userMap := make(map[int64]*tg.User)
 		for _, u := range users {
 			if user, ok := u.(*tg.User); ok {
@@ -234,12 +238,7 @@ func GetChatsOverview(c *client.Client) func(ctx context.Context, req *mcp.CallT
 		}
 
 		result := make([]ChatOverview, 0, len(dialogList))
-		for _, d := range dialogList {
-			dialog, ok := d.(*tg.Dialog)
-			if !ok {
-				continue
-			}
-
+		for _, dialog := range dialogList {
 			chat := ChatOverview{
 				UnreadCount: dialog.UnreadCount,
 			}
@@ -286,43 +285,21 @@ func GetChatsOverview(c *client.Client) func(ctx context.Context, req *mcp.CallT
 			}
 
 			if inputPeer != nil {
-				history, err := api.MessagesGetHistory(ctx, &tg.MessagesGetHistoryRequest{
+				request := &tg.MessagesGetHistoryRequest{
 					Peer:  inputPeer,
 					Limit: messagesLimit,
-				})
+				}
+				if input.UnreadOnly {
+					request.MinID = dialog.ReadInboxMaxID
+				}
+				history, err := api.MessagesGetHistory(ctx, request)
 				if err == nil {
-					messages, msgUsers := extractMessagesAndUsers(history)
-
-					msgUserMap := make(map[int64]string)
-					for _, u := range msgUsers {
-						if user, ok := u.(*tg.User); ok {
-							name := user.FirstName
-							if user.LastName != "" {
-								name += " " + user.LastName
-							}
-							msgUserMap[user.ID] = name
-						}
-					}
+					messages, msgUsers, msgChats := extractMessages(history)
+					refs := newPeerRefs(c, msgUsers, msgChats)
 
 					for _, msg := range messages {
-						if m, ok := msg.(*tg.Message); ok {
-							fromID := int64(0)
-							fromName := ""
-							if m.FromID != nil {
-								if userPeer, ok := m.FromID.(*tg.PeerUser); ok {
-									fromID = userPeer.UserID
-									fromName = msgUserMap[fromID]
-								}
-							}
-
-							chat.Messages = append(chat.Messages, Message{
-								ID:       m.ID,
-								Text:     m.Message,
-								FromID:   fromID,
-								FromName: fromName,
-								Date:     formatDate(m.Date),
-								IsOut:    m.Out,
-							})
+						if m, ok := convertMessage(msg, refs, input.Format); ok && !(input.UnreadOnly && m.IsOut) {
+							chat.Messages = append(chat.Messages, m)
 						}
 					}
 				}
@@ -339,13 +316,98 @@ func GetChatsOverview(c *client.Client) func(ctx context.Context, req *mcp.CallT
 }
 
 func RegisterChatsTools(server *mcp.Server, c *client.Client) {
-	mcp.AddTool(server, &mcp.Tool{
+	addUserTool(server, c, &mcp.Tool{
 		Name:        "list_chats",
 		Description: "Get list of Telegram dialogs/chats with unread counts",
 	}, ListChats(c))
 
-	mcp.AddTool(server, &mcp.Tool{
+	addUserTool(server, c, &mcp.Tool{
 		Name:        "get_chats_overview",
-		Description: "Get all chats with their recent messages in one request. Use chats_limit (default 20, max 50) and messages_limit (default 3, max 10) to control output size.",
+		Description: "Get all chats with their recent messages in one request. Use chats_limit (default 20, max 50) and messages_limit (default 3, max 10) to control output size. unread_only=true keeps only chats with unread messages and returns just their unread incoming messages. Supports the same format values as get_messages.",
 	}, GetChatsOverview(c))
 }
+
+// overviewDialogs returns the first limit dialogs, or with unreadOnly the
+// first limit unread ones, paging through the dialog list as needed.
+func overviewDialogs(ctx context.Context, c *client.Client, api *tg.Client, limit int, unreadOnly bool) ([]*tg.Dialog, []tg.ChatClass, []tg.UserClass, error) {
+	pageSize := limit
+	if unreadOnly {
+		pageSize = 100
+	}
+
+	var (
+		result     []*tg.Dialog
+		chats      []tg.ChatClass
+		users      []tg.UserClass
+		offsetDate int
+		offsetID   int
+		offsetPeer tg.InputPeerClass = &tg.InputPeerEmpty{}
+		seen                         = make(map[string]bool)
+	)
+	for {
+		resp, err := api.MessagesGetDialogs(ctx, &tg.MessagesGetDialogsRequest{
+			OffsetDate: offsetDate,
+			OffsetID:   offsetID,
+			OffsetPeer: offsetPeer,
+			Limit:      pageSize,
+		})
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		dialogs, pageChats, pageUsers := extractDialogsData(resp)
+		chats = append(chats, pageChats...)
+		users = append(users, pageUsers...)
+
+		added := 0
+		var last *tg.Dialog
+		for _, dc := range dialogs {
+			d, ok := dc.(*tg.Dialog)
+			if !ok {
+				continue
+			}
+			last = d
+			// Pinned dialogs can come back on later pages.
+			if seen[d.Peer.String()] {
+				continue
+			}
+			seen[d.Peer.String()] = true
+			added++
+
+			if unreadOnly && d.UnreadCount == 0 {
+				continue
+			}
+			result = append(result, d)
+			if len(result) == limit {
+				return result, chats, users, nil
+			}
+		}
+
+		slice, ok := resp.(*tg.MessagesDialogsSlice)
+		if !ok || len(dialogs) < pageSize || last == nil || added == 0 {
+			return result, chats, users, nil
+		}
+
+		offsetID = last.TopMessage
+		offsetDate = topMessageDate(slice.Messages, last)
+		offsetPeer = c.PeerOf(last.Peer).InputPeer()
+	}
+}
+
+// topMessageDate returns the date of a dialog's top message, which the next
+// dialog page is offset by.
+func topMessageDate(messages []tg.MessageClass, d *tg.Dialog) int {
+	for _, msg := range messages {
+		switch m := msg.(type) {
+		case *tg.Message:
+			if m.ID == d.TopMessage && m.PeerID.String() == d.Peer.String() {
+				return m.Date
+			}
+		case *tg.MessageService:
+			if m.ID == d.TopMessage && m.PeerID.String() == d.Peer.String() {
+				return m.Date
+			}
+		}
+	}
+	return 0
+}
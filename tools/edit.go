@@ -0,0 +1,198 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gotd/td/tgerr"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"tg-mcp/client"
+)
+
+const maxDeleteMessages = 100
+
+type EditMessageInput struct {
+	Chat      string `json:"chat"`
+	MessageID int    `json:"message_id"`
+	Text      string `json:"text"`
+	ParseMode string `json:"parse_mode,omitempty"`
+}
+
+type EditMessageOutput struct {
+	Success   bool   `json:"success"`
+	MessageID int    `json:"message_id,omitempty"`
+	Message   string `json:"message,omitempty"`
+}
+
+func EditMessage(c *client.Client) func(ctx context.Context, req *mcp.CallToolRequest, input EditMessageInput) (*mcp.CallToolResult, EditMessageOutput, error) {
+	return func(ctx context.Context, req *mcp.CallToolRequest, input EditMessageInput) (*mcp.CallToolResult, EditMessageOutput, error) {
+		if !c.IsAuthorized() {
+			return nil, EditMessageOutput{
+				Success: false,
+				Message: "Not authorized",
+			}, nil
+		}
+
+		sender := c.Sender()
+		if sender == nil {
+			return nil, EditMessageOutput{
+				Success: false,
+				Message: "Client is not running",
+			}, nil
+		}
+
+		inputPeer, err := c.ResolvePeer(ctx, input.Chat)
+		if err != nil {
+			return nil, EditMessageOutput{
+				Success: false,
+				Message: fmt.Sprintf("Failed to resolve chat: %v", err),
+			}, nil
+		}
+
+		text, err := styledText(c, input.Text, input.ParseMode)
+		if err != nil {
+			return nil, EditMessageOutput{
+				Success: false,
+				Message: fmt.Sprintf("Invalid formatting: %v", err),
+			}, nil
+		}
+
+		_, err = sender.To(inputPeer).Edit(input.MessageID).StyledText(ctx, text)
+		if tgerr.Is(err, "MESSAGE_NOT_MODIFIED") {
+			return nil, EditMessageOutput{
+				Success:   true,
+				MessageID: input.MessageID,
+				Message:   "Message already has this content",
+			}, nil
+		}
+		if err != nil {
+			return nil, EditMessageOutput{
+				Success: false,
+				Message: fmt.Sprintf("Failed to edit message: %v", err),
+			}, nil
+		}
+
+		return nil, EditMessageOutput{
+			Success:   true,
+			MessageID: input.MessageID,
+			Message:   "Message edited successfully",
+		}, nil
+	}
+}
+
+type DeleteMessagesInput struct {
+	Chat       string `json:"chat"`
+	MessageIDs []int  `json:"message_ids"`
+	Revoke     bool   `json:"revoke,omitempty"`
+}
+
+type DeleteResult struct {
+	ID     int    `json:"id"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type DeleteMessagesOutput struct {
+	Success bool           `json:"success"`
+	Deleted int            `json:"deleted"`
+	Results []DeleteResult `json:"results,omitempty"`
+	Message string         `json:"message,omitempty"`
+}
+
+func DeleteMessages(c *client.Client) func(ctx context.Context, req *mcp.CallToolRequest, input DeleteMessagesInput) (*mcp.CallToolResult, DeleteMessagesOutput, error) {
+	return func(ctx context.Context, req *mcp.CallToolRequest, input DeleteMessagesInput) (*mcp.CallToolResult, DeleteMessagesOutput, error) {
+		if !c.IsAuthorized() {
+			return nil, DeleteMessagesOutput{
+				Success: false,
+				Message: "Not authorized",
+			}, nil
+		}
+
+		if len(input.MessageIDs) == 0 || len(input.MessageIDs) > maxDeleteMessages {
+			return nil, DeleteMessagesOutput{
+				Success: false,
+				Message: fmt.Sprintf("Provide between 1 and %d message IDs", maxDeleteMessages),
+			}, nil
+		}
+
+		peer, err := c.Resolve(ctx, input.Chat)
+		if err != nil {
+			return nil, DeleteMessagesOutput{
+				Success: false,
+				Message: fmt.Sprintf("Failed to resolve chat: %v", err),
+			}, nil
+		}
+
+		// Look the messages up first so that IDs from other chats are never
+		// deleted; outside channels message IDs are not scoped to a chat.
+		existing, err := c.GetMessages(ctx, peer, input.MessageIDs)
+		if err != nil {
+			return nil, DeleteMessagesOutput{
+				Success: false,
+				Message: fmt.Sprintf("Failed to look up messages: %v", err),
+			}, nil
+		}
+
+		var order, ids []int
+		seen := make(map[int]bool)
+		for _, id := range input.MessageIDs {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			order = append(order, id)
+			if _, ok := existing[id]; ok {
+				ids = append(ids, id)
+			}
+		}
+
+		outcomes := make(map[int]error, len(ids))
+		if len(ids) > 0 {
+			if err := c.DeleteMessages(ctx, peer, ids, input.Revoke); err != nil && len(ids) > 1 {
+				// Retry one by one to find out which messages failed.
+				for _, id := range ids {
+					outcomes[id] = c.DeleteMessages(ctx, peer, []int{id}, input.Revoke)
+				}
+			} else {
+				for _, id := range ids {
+					outcomes[id] = err
+				}
+			}
+		}
+
+		results := make([]DeleteResult, 0, len(order))
+		deleted := 0
+		for _, id := range order {
+			err, found := outcomes[id]
+			switch {
+			case !found:
+				results = append(results, DeleteResult{ID: id, Status: "not_found"})
+			case err != nil:
+				results = append(results, DeleteResult{ID: id, Status: "failed", Error: err.Error()})
+			default:
+				results = append(results, DeleteResult{ID: id, Status: "deleted"})
+				deleted++
+			}
+		}
+
+		return nil, DeleteMessagesOutput{
+			Success: deleted > 0,
+			Deleted: deleted,
+			Results: results,
+			Message: fmt.Sprintf("Deleted %d of %d messages", deleted, len(order)),
+		}, nil
+	}
+}
+
+func RegisterEditTools(server *mcp.Server, c *client.Client) {
+	addTool(server, c, &mcp.Tool{
+		Name:        "edit_message",
+		Description: "Edit the text of a message, or the caption of a media message. Supports the same parse_mode values as send_message.",
+	}, EditMessage(c))
+
+	addTool(server, c, &mcp.Tool{
+		Name:        "delete_messages",
+		Description: "Delete up to 100 messages from a chat by ID. revoke=true also deletes them for the other participants of private chats and basic groups; in channels and supergroups messages are always deleted for everyone. Returns the outcome for each ID.",
+	}, DeleteMessages(c))
+}
@@ -0,0 +1,366 @@
+package tools
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gotd/td/telegram/message"
+	"github.com/gotd/td/tg"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"tg-mcp/client"
+)
+
+const maxAlbumSize = 10
+
+// FileItem describes one file to send, read either from a local path or from
+// base64-encoded data.
+type FileItem struct {
+	Path      string `json:"path,omitempty"`
+	Data      string `json:"data,omitempty"`
+	FileName  string `json:"file_name,omitempty"`
+	MimeType  string `json:"mime_type,omitempty"`
+	Type      string `json:"type,omitempty"`
+	Caption   string `json:"caption,omitempty"`
+	ParseMode string `json:"parse_mode,omitempty"`
+	Duration  int    `json:"duration,omitempty"`
+	Width     int    `json:"width,omitempty"`
+	Height    int    `json:"height,omitempty"`
+}
+
+type SendFileInput struct {
+	Chat         string `json:"chat"`
+	TopicID      int    `json:"topic_id,omitempty"`
+	ReplyTo      int    `json:"reply_to,omitempty"`
+	ScheduleDate string `json:"schedule_date,omitempty"`
+	FileItem
+}
+
+type SendFileOutput struct {
+	Success   bool   `json:"success"`
+	MessageID int    `json:"message_id,omitempty"`
+	Message   string `json:"message,omitempty"`
+}
+
+type SendAlbumInput struct {
+	Chat         string     `json:"chat"`
+	TopicID      int        `json:"topic_id,omitempty"`
+	ReplyTo      int        `json:"reply_to,omitempty"`
+	ScheduleDate string     `json:"schedule_date,omitempty"`
+	Items        []FileItem `json:"items"`
+}
+
+type SendAlbumOutput struct {
+	Success    bool   `json:"success"`
+	MessageIDs []int  `json:"message_ids,omitempty"`
+	Message    string `json:"message,omitempty"`
+}
+
+func SendFile(c *client.Client) func(ctx context.Context, req *mcp.CallToolRequest, input SendFileInput) (*mcp.CallToolResult, SendFileOutput, error) {
+	return func(ctx context.Context, req *mcp.CallToolRequest, input SendFileInput) (*mcp.CallToolResult, SendFileOutput, error) {
+		if !c.IsAuthorized() {
+			return nil, SendFileOutput{
+				Success: false,
+				Message: "Not authorized",
+			}, nil
+		}
+
+		sender := c.Sender()
+		if sender == nil {
+			return nil, SendFileOutput{
+				Success: false,
+				Message: "Client is not running",
+			}, nil
+		}
+
+		if input.ScheduleDate != "" && c.IsBot() {
+			return nil, SendFileOutput{
+				Success: false,
+				Message: "Bots cannot schedule messages",
+			}, nil
+		}
+
+		when, err := parseScheduleDate(input.ScheduleDate, time.Now())
+		if err != nil {
+			return nil, SendFileOutput{
+				Success: false,
+				Message: fmt.Sprintf("Invalid schedule_date: %v", err),
+			}, nil
+		}
+
+		inputPeer, err := c.ResolvePeer(ctx, input.Chat)
+		if err != nil {
+			return nil, SendFileOutput{
+				Success: false,
+				Message: fmt.Sprintf("Failed to resolve chat: %v", err),
+			}, nil
+		}
+
+		builder := &sender.To(inputPeer).Builder
+		if id := threadReply(input.ReplyTo, input.TopicID); id != 0 {
+			builder = builder.Reply(id)
+		}
+		builder = schedule(builder, when)
+
+		media, err := uploadItem(ctx, c, builder, input.FileItem, false)
+		if err != nil {
+			return nil, SendFileOutput{
+				Success: false,
+				Message: fmt.Sprintf("Failed to upload file: %v", err),
+			}, nil
+		}
+
+		updates, err := builder.Media(ctx, media)
+		if err != nil {
+			return nil, SendFileOutput{
+				Success: false,
+				Message: fmt.Sprintf("Failed to send file: %v", err),
+			}, nil
+		}
+
+		return nil, SendFileOutput{
+			Success:   true,
+			MessageID: extractMessageID(updates),
+			Message:   sentMessage("File sent successfully", when),
+		}, nil
+	}
+}
+
+func SendAlbum(c *client.Client) func(ctx context.Context, req *mcp.CallToolRequest, input SendAlbumInput) (*mcp.CallToolResult, SendAlbumOutput, error) {
+	return func(ctx context.Context, req *mcp.CallToolRequest, input SendAlbumInput) (*mcp.CallToolResult, SendAlbumOutput, error) {
+		if !c.IsAuthorized() {
+			return nil, SendAlbumOutput{
+				Success: false,
+				Message: "Not authorized",
+			}, nil
+		}
+
+		if len(input.Items) < 2 || len(input.Items) > maxAlbumSize {
+			return nil, SendAlbumOutput{
+				Success: false,
+				Message: fmt.Sprintf("An album needs between 2 and %d items", maxAlbumSize),
+			}, nil
+		}
+
+		sender := c.Sender()
+		if sender == nil {
+			return nil, SendAlbumOutput{
+				Success: false,
+				Message: "Client is not running",
+			}, nil
+		}
+
+		if input.ScheduleDate != "" && c.IsBot() {
+			return nil, SendAlbumOutput{
+				Success: false,
+				Message: "Bots cannot schedule messages",
+			}, nil
+		}
+
+		when, err := parseScheduleDate(input.ScheduleDate, time.Now())
+		if err != nil {
+			return nil, SendAlbumOutput{
+				Success: false,
+				Message: fmt.Sprintf("Invalid schedule_date: %v", err),
+			}, nil
+		}
+
+		inputPeer, err := c.ResolvePeer(ctx, input.Chat)
+		if err != nil {
+			return nil, SendAlbumOutput{
+				Success: false,
+				Message: fmt.Sprintf("Failed to resolve chat: %v", err),
+			}, nil
+		}
+
+		builder := &sender.To(inputPeer).Builder
+		if id := threadReply(input.ReplyTo, input.TopicID); id != 0 {
+			builder = builder.Reply(id)
+		}
+		builder = schedule(builder, when)
+
+		media := make([]message.MultiMediaOption, 0, len(input.Items))
+		for i, item := range input.Items {
+			m, err := uploadItem(ctx, c, builder, item, true)
+			if err != nil {
+				return nil, SendAlbumOutput{
+					Success: false,
+					Message: fmt.Sprintf("Failed to upload item %d: %v", i+1, err),
+				}, nil
+			}
+			media = append(media, m)
+		}
+
+		updates, err := builder.Album(ctx, media[0], media[1:]...)
+		if err != nil {
+			return nil, SendAlbumOutput{
+				Success: false,
+				Message: fmt.Sprintf("Failed to send album: %v", err),
+			}, nil
+		}
+
+		return nil, SendAlbumOutput{
+			Success:    true,
+			MessageIDs: extractMessageIDs(updates),
+			Message:    sentMessage("Album sent successfully", when),
+		}, nil
+	}
+}
+
+// uploadItem uploads the file and wraps it as photo or document media
+// according to item.Type, detecting the type from the MIME type if unset.
+func uploadItem(ctx context.Context, c *client.Client, builder *message.Builder, item FileItem, album bool) (message.MultiMediaOption, error) {
+	var (
+		source   message.UploadOption
+		name     = item.FileName
+		mimeType = item.MimeType
+	)
+
+	switch {
+	case item.Path != "" && item.Data != "":
+		return nil, fmt.Errorf("path and data are mutually exclusive")
+	case item.Path != "":
+		path, err := c.UploadPath(item.Path)
+		if err != nil {
+			return nil, err
+		}
+		if name == "" {
+			name = filepath.Base(item.Path)
+		}
+		if mimeType == "" {
+			mimeType = mime.TypeByExtension(filepath.Ext(item.Path))
+		}
+		source = message.FromPath(path)
+	case item.Data != "":
+		data, err := base64.StdEncoding.DecodeString(item.Data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid base64 data: %w", err)
+		}
+		if mimeType == "" && name != "" {
+			mimeType = mime.TypeByExtension(filepath.Ext(name))
+		}
+		if mimeType == "" {
+			mimeType = http.DetectContentType(data)
+		}
+		if name == "" {
+			name = "file"
+			if exts, _ := mime.ExtensionsByType(mimeType); len(exts) > 0 {
+				name += exts[0]
+			}
+		}
+		source = message.FromBytes(name, data)
+	default:
+		return nil, fmt.Errorf("either path or data is required")
+	}
+
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+	// Strip parameters such as "; charset=utf-8" from detected types.
+	mimeType, _, _ = strings.Cut(mimeType, ";")
+
+	kind := item.Type
+	if kind == "" {
+		kind = detectFileType(mimeType)
+	}
+	switch kind {
+	case "photo", "document", "video", "video_note", "audio", "voice", "animation":
+	default:
+		return nil, fmt.Errorf("unknown file type %q", kind)
+	}
+	if album && (kind == "voice" || kind == "video_note") {
+		return nil, fmt.Errorf("%s messages cannot be sent in an album", kind)
+	}
+
+	var caption []message.StyledTextOption
+	if item.Caption != "" {
+		text, err := styledText(c, item.Caption, item.ParseMode)
+		if err != nil {
+			return nil, fmt.Errorf("invalid caption formatting: %w", err)
+		}
+		caption = append(caption, text)
+	}
+
+	file, err := builder.Upload(source).AsInputFile(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := message.UploadedDocument(file, caption...).MIME(mimeType).Filename(name)
+
+	switch kind {
+	case "photo":
+		return message.UploadedPhoto(file, caption...), nil
+	case "document":
+		return doc.ForceFile(true), nil
+	case "video":
+		video := doc.Video().SupportsStreaming().DurationSeconds(item.Duration)
+		if item.Width > 0 && item.Height > 0 {
+			video = video.Resolution(item.Width, item.Height)
+		}
+		return video, nil
+	case "video_note":
+		size := max(item.Width, item.Height)
+		return doc.RoundVideo().DurationSeconds(item.Duration).Resolution(size, size), nil
+	case "audio":
+		return doc.Audio().DurationSeconds(item.Duration), nil
+	case "voice":
+		return doc.Voice().DurationSeconds(item.Duration), nil
+	default: // animation
+		return doc.Attributes(&tg.DocumentAttributeAnimated{}), nil
+	}
+}
+
+func detectFileType(mimeType string) string {
+	switch {
+	case mimeType == "image/jpeg", mimeType == "image/png", mimeType == "image/webp":
+		return "photo"
+	case mimeType == "image/gif":
+		return "animation"
+	case strings.HasPrefix(mimeType, "video/"):
+		return "video"
+	case mimeType == "audio/ogg":
+		return "voice"
+	case strings.HasPrefix(mimeType, "audio/"):
+		return "audio"
+	default:
+		return "document"
+	}
+}
+
+// extractMessageIDs returns the IDs of all messages created by a request in
+// ascending order, which for albums matches the order of the items.
+func extractMessageIDs(updates tg.UpdatesClass) []int {
+	var ids []int
+	switch u := updates.(type) {
+	case *tg.Updates:
+		for _, update := range u.Updates {
+			if msg, ok := update.(*tg.UpdateMessageID); ok {
+				ids = append(ids, msg.ID)
+			}
+		}
+	case *tg.UpdateShortSentMessage:
+		ids = append(ids, u.ID)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+func RegisterFilesTools(server *mcp.Server, c *client.Client) {
+	addTool(server, c, &mcp.Tool{
+		Name:        "send_file",
+		Description: "Send a file from a local path inside the upload directory (TG_UPLOAD_DIR, default ~/tg-mcp-uploads; relative paths are taken from it) or base64 data. type is one of photo, document, video, video_note, audio, voice, animation and is detected from the MIME type if omitted; use document to send images or videos uncompressed. Optional caption (parse_mode plain, markdown or html), duration/width/height for video and audio, reply_to message ID, topic_id to post into a forum topic, schedule_date to send later (same as send_message, not available to bots).",
+	}, SendFile(c))
+
+	addTool(server, c, &mcp.Tool{
+		Name:        "send_album",
+		Description: "Send 2-10 files as a grouped album. Each item takes the same fields as send_file; photos and videos can be mixed, documents and audio must be grouped with their own kind. Returns the message IDs in item order.",
+	}, SendAlbum(c))
+}
@@ -0,0 +1,81 @@
+package tools
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/gotd/td/tg"
+
+	"tg-mcp/format"
+)
+
+// formatEntities returns the raw entity list instead of rendered markup.
+const formatEntities = "entities"
+
+// Entity is a formatting span of a message. Offset and Length count UTF-16
+// code units, as in the Telegram API.
+type Entity struct {
+	Type     string `json:"type"`
+	Offset   int    `json:"offset"`
+	Length   int    `json:"length"`
+	URL      string `json:"url,omitempty"`
+	Language string `json:"language,omitempty"`
+	UserID   int64  `json:"user_id,omitempty"`
+}
+
+func validTextFormat(mode string) bool {
+	switch mode {
+	case "", format.ModePlain, format.ModeMarkdown, format.ModeHTML, formatEntities:
+		return true
+	}
+	return false
+}
+
+// applyTextFormat renders the message text in the requested format, or
+// attaches its entities for the "entities" format.
+func applyTextFormat(msg *Message, m *tg.Message, mode string) {
+	msg.Text = format.Render(m.Message, m.Entities, mode)
+	if mode != formatEntities {
+		return
+	}
+
+	msg.Entities = make([]Entity, 0, len(m.Entities))
+	for _, e := range m.Entities {
+		entity := Entity{
+			Type:   entityType(e),
+			Offset: e.GetOffset(),
+			Length: e.GetLength(),
+		}
+		switch e := e.(type) {
+		case *tg.MessageEntityTextURL:
+			entity.URL = e.URL
+		case *tg.MessageEntityPre:
+			entity.Language = e.Language
+		case *tg.MessageEntityMentionName:
+			entity.UserID = e.UserID
+		}
+		msg.Entities = append(msg.Entities, entity)
+	}
+}
+
+func entityType(e tg.MessageEntityClass) string {
+	return typeName(e.TypeName(), "messageEntity")
+}
+
+// typeName converts a TL name such as messageEntityTextUrl, with its prefix
+// removed, to text_url.
+func typeName(tlName, prefix string) string {
+	name := strings.TrimPrefix(tlName, prefix)
+
+	var b strings.Builder
+	for i, r := range name {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -3,7 +3,6 @@ package tools
 import (
 	"context"
 	"fmt"
-	"strconv"
 
 	"github.com/gotd/td/tg"
 	"github.com/modelcontextprotocol/go-sdk/mcp"
@@ -37,7 +36,7 @@ func DeleteChat(c *client.Client) func(ctx context.Context, req *mcp.CallToolReq
 			}, nil
 		}
 
-		inputPeer, err := getPeerFromDialogs(ctx, api, input.Chat)
+		inputPeer, err := c.ResolvePeer(ctx, input.Chat)
 		if err != nil {
 			return nil, DeleteChatOutput{
 				Success: false,
@@ -90,9 +89,17 @@ func LeaveChannel(c *client.Client) func(ctx context.Context, req *mcp.CallToolR
 			}, nil
 		}
 
-		inputChannel, err := getChannelFromDialogs(ctx, api, input.Channel)
-		if err == nil {
-			_, err = api.ChannelsLeaveChannel(ctx, inputChannel)
+		inputPeer, err := c.ResolvePeer(ctx, input.Channel)
+		if err != nil {
+			return nil, LeaveChannelOutput{
+				Success: false,
+				Message: fmt.Sprintf("Failed to find channel/group: %v", err),
+			}, nil
+		}
+
+		switch p := inputPeer.(type) {
+		case *tg.InputPeerChannel:
+			_, err = api.ChannelsLeaveChannel(ctx, &tg.InputChannel{ChannelID: p.ChannelID, AccessHash: p.AccessHash})
 			if err != nil {
 				return nil, LeaveChannelOutput{
 					Success: false,
@@ -103,25 +110,22 @@ func LeaveChannel(c *client.Client) func(ctx context.Context, req *mcp.CallToolR
 				Success: true,
 				Message: "Left channel/supergroup",
 			}, nil
-		}
-
-		chatID, err := getChatIDFromDialogs(ctx, api, input.Channel)
-		if err != nil {
-			return nil, LeaveChannelOutput{
-				Success: false,
-				Message: fmt.Sprintf("Failed to find channel/group: %v", err),
-			}, nil
-		}
-
-		_, err = api.MessagesDeleteChatUser(ctx, &tg.MessagesDeleteChatUserRequest{
-			ChatID:        chatID,
-			UserID:        &tg.InputUserSelf{},
-			RevokeHistory: true,
-		})
-		if err != nil {
+		case *tg.InputPeerChat:
+			_, err = api.MessagesDeleteChatUser(ctx, &tg.MessagesDeleteChatUserRequest{
+				ChatID:        p.ChatID,
+				UserID:        &tg.InputUserSelf{},
+				RevokeHistory: true,
+			})
+			if err != nil {
+				return nil, LeaveChannelOutput{
+					Success: false,
+					Message: fmt.Sprintf("Failed to leave group: %v", err),
+				}, nil
+			}
+		default:
 			return nil, LeaveChannelOutput{
 				Success: false,
-				Message: fmt.Sprintf("Failed to leave group: %v", err),
+				Message: fmt.Sprintf("%s is not a channel or group", input.Channel),
 			}, nil
 		}
 
@@ -132,140 +136,64 @@ func LeaveChannel(c *client.Client) func(ctx context.Context, req *mcp.CallToolR
 	}
 }
 
-func getPeerFromDialogs(ctx context.Context, api *tg.Client, chat string) (tg.InputPeerClass, error) {
-	dialogs, err := api.MessagesGetDialogs(ctx, &tg.MessagesGetDialogsRequest{
-		OffsetPeer: &tg.InputPeerEmpty{},
-		Limit:      200,
-	})
-	if err != nil {
-		return nil, err
-	}
-
-	chatID, isNumeric := parseID(chat)
-
-	var users []tg.UserClass
-	var chats []tg.ChatClass
+type MarkReadInput struct {
+	Chat  string `json:"chat"`
+	MaxID int    `json:"max_id,omitempty"`
+}
 
-	switch d := dialogs.(type) {
-	case *tg.MessagesDialogs:
-		users = d.Users
-		chats = d.Chats
-	case *tg.MessagesDialogsSlice:
-		users = d.Users
-		chats = d.Chats
-	}
+type MarkReadOutput struct {
+	Success bool   `json:"success"`
+	Message string `json:"message,omitempty"`
+}
 
-	for _, u := range users {
-		if user, ok := u.(*tg.User); ok {
-			if isNumeric && user.ID == chatID {
-				return &tg.InputPeerUser{UserID: user.ID, AccessHash: user.AccessHash}, nil
-			}
-			if !isNumeric && user.Username == chat {
-				return &tg.InputPeerUser{UserID: user.ID, AccessHash: user.AccessHash}, nil
-			}
+func MarkRead(c *client.Client) func(ctx context.Context, req *mcp.CallToolRequest, input MarkReadInput) (*mcp.CallToolResult, MarkReadOutput, error) {
+	return func(ctx context.Context, req *mcp.CallToolRequest, input MarkReadInput) (*mcp.CallToolResult, MarkReadOutput, error) {
+		if !c.IsAuthorized() {
+			return nil, MarkReadOutput{
+				Success: false,
+				Message: "Not authorized",
+			}, nil
 		}
-	}
 
-	for _, ch := range chats {
-		switch c := ch.(type) {
-		case *tg.Chat:
-			if isNumeric && c.ID == chatID {
-				return &tg.InputPeerChat{ChatID: c.ID}, nil
-			}
-		case *tg.Channel:
-			if isNumeric && c.ID == chatID {
-				return &tg.InputPeerChannel{ChannelID: c.ID, AccessHash: c.AccessHash}, nil
-			}
-			if !isNumeric && c.Username == chat {
-				return &tg.InputPeerChannel{ChannelID: c.ID, AccessHash: c.AccessHash}, nil
-			}
+		peer, err := c.Resolve(ctx, input.Chat)
+		if err != nil {
+			return nil, MarkReadOutput{
+				Success: false,
+				Message: fmt.Sprintf("Failed to find chat: %v", err),
+			}, nil
 		}
-	}
-
-	return nil, fmt.Errorf("chat not found: %s", chat)
-}
 
-func getChannelFromDialogs(ctx context.Context, api *tg.Client, channel string) (*tg.InputChannel, error) {
-	dialogs, err := api.MessagesGetDialogs(ctx, &tg.MessagesGetDialogsRequest{
-		OffsetPeer: &tg.InputPeerEmpty{},
-		Limit:      200,
-	})
-	if err != nil {
-		return nil, err
-	}
-
-	channelID, isNumeric := parseID(channel)
-
-	var chats []tg.ChatClass
-
-	switch d := dialogs.(type) {
-	case *tg.MessagesDialogs:
-		chats = d.Chats
-	case *tg.MessagesDialogsSlice:
-		chats = d.Chats
-	}
-
-	for _, ch := range chats {
-		if c, ok := ch.(*tg.Channel); ok {
-			if isNumeric && c.ID == channelID {
-				return &tg.InputChannel{ChannelID: c.ID, AccessHash: c.AccessHash}, nil
-			}
-			if !isNumeric && c.Username == channel {
-				return &tg.InputChannel{ChannelID: c.ID, AccessHash: c.AccessHash}, nil
-			}
+		if err := c.MarkRead(ctx, peer, input.MaxID); err != nil {
+			return nil, MarkReadOutput{
+				Success: false,
+				Message: fmt.Sprintf("Failed to mark as read: %v", err),
+			}, nil
 		}
-	}
-
-	return nil, fmt.Errorf("channel not found: %s", channel)
-}
-
-func parseID(s string) (int64, bool) {
-	id, err := strconv.ParseInt(s, 10, 64)
-	return id, err == nil
-}
-
-func getChatIDFromDialogs(ctx context.Context, api *tg.Client, chat string) (int64, error) {
-	dialogs, err := api.MessagesGetDialogs(ctx, &tg.MessagesGetDialogsRequest{
-		OffsetPeer: &tg.InputPeerEmpty{},
-		Limit:      200,
-	})
-	if err != nil {
-		return 0, err
-	}
-
-	chatID, isNumeric := parseID(chat)
 
-	var chats []tg.ChatClass
-
-	switch d := dialogs.(type) {
-	case *tg.MessagesDialogs:
-		chats = d.Chats
-	case *tg.MessagesDialogsSlice:
-		chats = d.Chats
-	}
-
-	for _, ch := range chats {
-		if c, ok := ch.(*tg.Chat); ok {
-			if isNumeric && c.ID == chatID {
-				return c.ID, nil
-			}
-			if !isNumeric && c.Title == chat {
-				return c.ID, nil
-			}
+		message := "Chat marked as read"
+		if input.MaxID != 0 {
+			message = fmt.Sprintf("Messages up to %d marked as read", input.MaxID)
 		}
+		return nil, MarkReadOutput{
+			Success: true,
+			Message: message,
+		}, nil
 	}
-
-	return 0, fmt.Errorf("group not found: %s", chat)
 }
 
 func RegisterManageTools(server *mcp.Server, c *client.Client) {
-	mcp.AddTool(server, &mcp.Tool{
+	addUserTool(server, c, &mcp.Tool{
 		Name:        "delete_chat",
 		Description: "Delete a chat/dialog by username or ID (removes chat history)",
 	}, DeleteChat(c))
 
-	mcp.AddTool(server, &mcp.Tool{
+	addTool(server, c, &mcp.Tool{
 		Name:        "leave_channel",
 		Description: "Leave a channel or group by username or ID",
 	}, LeaveChannel(c))
+
+	addUserTool(server, c, &mcp.Tool{
+		Name:        "mark_read",
+		Description: "Mark a chat as read. With max_id, only messages up to that ID are marked read, e.g. the newest one returned by get_messages with unread_only=true; without it, unread mentions and reactions are cleared too.",
+	}, MarkRead(c))
 }
@@ -0,0 +1,234 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"path/filepath"
+	"strings"
+
+	"github.com/gotd/td/tg"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"tg-mcp/client"
+)
+
+// maxInlineSize caps files returned directly in the tool result.
+const maxInlineSize = 5 * 1024 * 1024
+
+type Media struct {
+	Type     string  `json:"type"`
+	FileName string  `json:"file_name,omitempty"`
+	MimeType string  `json:"mime_type,omitempty"`
+	Size     int64   `json:"size,omitempty"`
+	Width    int     `json:"width,omitempty"`
+	Height   int     `json:"height,omitempty"`
+	Duration float64 `json:"duration,omitempty"`
+}
+
+func describeMedia(media tg.MessageMediaClass) *Media {
+	switch m := media.(type) {
+	case nil, *tg.MessageMediaEmpty:
+		return nil
+	case *tg.MessageMediaPhoto:
+		result := &Media{Type: "photo", MimeType: "image/jpeg"}
+		if photo, ok := m.Photo.(*tg.Photo); ok {
+			if size, ok := client.LargestPhotoSize(photo); ok {
+				result.Width = size.W
+				result.Height = size.H
+				result.Size = int64(size.Size)
+			}
+		}
+		return result
+	case *tg.MessageMediaDocument:
+		doc, ok := m.Document.(*tg.Document)
+		if !ok {
+			return &Media{Type: "document"}
+		}
+		return describeDocument(doc)
+	case *tg.MessageMediaGeo, *tg.MessageMediaGeoLive, *tg.MessageMediaVenue:
+		return &Media{Type: "location"}
+	case *tg.MessageMediaContact:
+		return &Media{Type: "contact"}
+	case *tg.MessageMediaPoll:
+		return &Media{Type: "poll"}
+	case *tg.MessageMediaWebPage:
+		return &Media{Type: "webpage"}
+	case *tg.MessageMediaDice:
+		return &Media{Type: "dice"}
+	default:
+		return &Media{Type: "other"}
+	}
+}
+
+func describeDocument(doc *tg.Document) *Media {
+	result := &Media{
+		Type:     "document",
+		MimeType: doc.MimeType,
+		Size:     doc.Size,
+	}
+
+	for _, attr := range doc.Attributes {
+		switch a := attr.(type) {
+		case *tg.DocumentAttributeFilename:
+			result.FileName = a.FileName
+		case *tg.DocumentAttributeImageSize:
+			result.Width = a.W
+			result.Height = a.H
+		case *tg.DocumentAttributeVideo:
+			result.Type = "video"
+			if a.RoundMessage {
+				result.Type = "video_note"
+			}
+			result.Width = a.W
+			result.Height = a.H
+			result.Duration = a.Duration
+		case *tg.DocumentAttributeAudio:
+			result.Type = "audio"
+			if a.Voice {
+				result.Type = "voice"
+			}
+			result.Duration = float64(a.Duration)
+		case *tg.DocumentAttributeSticker:
+			result.Type = "sticker"
+		case *tg.DocumentAttributeAnimated:
+			result.Type = "animation"
+		}
+	}
+
+	return result
+}
+
+type DownloadMediaInput struct {
+	Chat      string `json:"chat"`
+	MessageID int    `json:"message_id"`
+	Inline    bool   `json:"inline,omitempty"`
+}
+
+type DownloadMediaOutput struct {
+	Success bool   `json:"success"`
+	Path    string `json:"path,omitempty"`
+	Media   *Media `json:"media,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+func DownloadMedia(c *client.Client) func(ctx context.Context, req *mcp.CallToolRequest, input DownloadMediaInput) (*mcp.CallToolResult, DownloadMediaOutput, error) {
+	return func(ctx context.Context, req *mcp.CallToolRequest, input DownloadMediaInput) (*mcp.CallToolResult, DownloadMediaOutput, error) {
+		if !c.IsAuthorized() {
+			return nil, DownloadMediaOutput{
+				Success: false,
+				Message: "Not authorized",
+			}, nil
+		}
+
+		peer, err := c.Resolve(ctx, input.Chat)
+		if err != nil {
+			return nil, DownloadMediaOutput{
+				Success: false,
+				Message: fmt.Sprintf("Failed to resolve chat: %v", err),
+			}, nil
+		}
+
+		msg, err := c.GetMessage(ctx, peer, input.MessageID)
+		if err != nil {
+			return nil, DownloadMediaOutput{
+				Success: false,
+				Message: fmt.Sprintf("Failed to get message: %v", err),
+			}, nil
+		}
+
+		m, ok := msg.(*tg.Message)
+		if !ok || m.Media == nil {
+			return nil, DownloadMediaOutput{
+				Success: false,
+				Message: "Message has no media",
+			}, nil
+		}
+
+		media := describeMedia(m.Media)
+		file, ok := client.FileOf(m.Media)
+		if !ok {
+			return nil, DownloadMediaOutput{
+				Success: false,
+				Media:   media,
+				Message: fmt.Sprintf("Media of type %s cannot be downloaded", media.Type),
+			}, nil
+		}
+
+		if input.Inline && file.Size <= maxInlineSize {
+			data, err := c.DownloadBytes(ctx, file.Location, maxInlineSize)
+			if err != nil {
+				return nil, DownloadMediaOutput{
+					Success: false,
+					Media:   media,
+					Message: fmt.Sprintf("Failed to download media: %v", err),
+				}, nil
+			}
+
+			output := DownloadMediaOutput{
+				Success: true,
+				Media:   media,
+				Message: fmt.Sprintf("Downloaded %d bytes", len(data)),
+			}
+			outputJSON, err := json.Marshal(output)
+			if err != nil {
+				return nil, DownloadMediaOutput{}, err
+			}
+
+			var content mcp.Content
+			if strings.HasPrefix(file.MimeType, "image/") {
+				content = &mcp.ImageContent{Data: data, MIMEType: file.MimeType}
+			} else {
+				content = &mcp.EmbeddedResource{Resource: &mcp.ResourceContents{
+					URI:      fmt.Sprintf("tg://message/%s/%d/%d", peer.Kind, peer.ID, m.ID),
+					MIMEType: file.MimeType,
+					Blob:     data,
+				}}
+			}
+
+			return &mcp.CallToolResult{
+				Content: []mcp.Content{content, &mcp.TextContent{Text: string(outputJSON)}},
+			}, output, nil
+		}
+
+		path := filepath.Join(c.DownloadDir(), mediaFileName(peer, m.ID, file))
+		if err := c.DownloadFile(ctx, file.Location, path); err != nil {
+			return nil, DownloadMediaOutput{
+				Success: false,
+				Media:   media,
+				Message: fmt.Sprintf("Failed to download media: %v", err),
+			}, nil
+		}
+
+		message := "Media saved"
+		if input.Inline {
+			message = fmt.Sprintf("File is larger than %d bytes, saved to disk instead", maxInlineSize)
+		}
+
+		return nil, DownloadMediaOutput{
+			Success: true,
+			Path:    path,
+			Media:   media,
+			Message: message,
+		}, nil
+	}
+}
+
+func mediaFileName(peer client.Peer, messageID int, file client.MediaFile) string {
+	name := filepath.Base(file.FileName)
+	if file.FileName == "" || name == "." || name == string(filepath.Separator) {
+		name = "media"
+		if exts, _ := mime.ExtensionsByType(file.MimeType); len(exts) > 0 {
+			name += exts[0]
+		}
+	}
+	return fmt.Sprintf("%d_%d_%s", peer.ID, messageID, name)
+}
+
+func RegisterMediaTools(server *mcp.Server, c *client.Client) {
+	addTool(server, c, &mcp.Tool{
+		Name:        "download_media",
+		Description: "Download the photo, video, voice note or document attached to a message. Saves to the download directory (TG_DOWNLOAD_DIR) and returns the path, or with inline=true returns files up to 5 MB directly as image or embedded resource content.",
+	}, DownloadMedia(c))
+}
@@ -3,8 +3,6 @@ package tools
 import (
 	"context"
 	"fmt"
-	"strings"
-	"time"
 
 	"github.com/gotd/td/tg"
 	"github.com/modelcontextprotocol/go-sdk/mcp"
@@ -13,23 +11,73 @@ import (
 )
 
 type GetMessagesInput struct {
-	Chat  string `json:"chat"`
-	Limit int    `json:"limit,omitempty"`
+	Chat       string `json:"chat"`
+	Limit      int    `json:"limit,omitempty"`
+	TopicID    int    `json:"topic_id,omitempty"`
+	UnreadOnly bool   `json:"unread_only,omitempty"`
+	Refresh    bool   `json:"refresh,omitempty"`
+	Format     string `json:"format,omitempty"`
 }
 
 type Message struct {
-	ID       int    `json:"id"`
-	Text     string `json:"text"`
-	FromID   int64  `json:"from_id,omitempty"`
-	FromName string `json:"from_name,omitempty"`
-	Date     string `json:"date"`
-	IsOut    bool   `json:"is_out"`
+	ID            int        `json:"id"`
+	Text          string     `json:"text"`
+	FromID        int64      `json:"from_id,omitempty"`
+	FromName      string     `json:"from_name,omitempty"`
+	FromType      string     `json:"from_type,omitempty"`
+	Date          string     `json:"date"`
+	EditDate      string     `json:"edit_date,omitempty"`
+	IsOut         bool       `json:"is_out"`
+	ReplyTo       *ReplyTo   `json:"reply_to,omitempty"`
+	ForwardedFrom *Forward   `json:"forwarded_from,omitempty"`
+	ViaBot        *ChatRef   `json:"via_bot,omitempty"`
+	PostAuthor    string     `json:"post_author,omitempty"`
+	Views         int        `json:"views,omitempty"`
+	Forwards      int        `json:"forwards,omitempty"`
+	GroupedID     int64      `json:"grouped_id,omitempty"`
+	Media         *Media     `json:"media,omitempty"`
+	Entities      []Entity   `json:"entities,omitempty"`
+	Poll          *Poll      `json:"poll,omitempty"`
+	Reactions     []Reaction `json:"reactions,omitempty"`
+	Action        *Action    `json:"action,omitempty"`
+}
+
+// ReplyTo identifies the message being replied to. Chat is set when it is in
+// a different chat; TopID is the root of the thread or forum topic.
+type ReplyTo struct {
+	MessageID int      `json:"message_id,omitempty"`
+	TopID     int      `json:"top_id,omitempty"`
+	Chat      *ChatRef `json:"chat,omitempty"`
+	Quote     string   `json:"quote,omitempty"`
+}
+
+// Forward describes the origin of a forwarded message. From is empty when
+// the original sender hides their account; Name is then all that is known.
+type Forward struct {
+	From       *ChatRef `json:"from,omitempty"`
+	Name       string   `json:"name,omitempty"`
+	Date       string   `json:"date"`
+	MessageID  int      `json:"message_id,omitempty"`
+	PostAuthor string   `json:"post_author,omitempty"`
+}
+
+type ChatRef struct {
+	ID       int64  `json:"id"`
+	Type     string `json:"type"`
+	Title    string `json:"title,omitempty"`
+	Username string `json:"username,omitempty"`
+}
+
+type ChatMessage struct {
+	Chat ChatRef `json:"chat"`
+	Message
 }
 
 type GetMessagesOutput struct {
-	Success  bool      `json:"success"`
-	Messages []Message `json:"messages,omitempty"`
-	Message  string    `json:"message,omitempty"`
+	Success     bool      `json:"success"`
+	Messages    []Message `json:"messages,omitempty"`
+	UnreadCount int       `json:"unread_count,omitempty"`
+	Message     string    `json:"message,omitempty"`
 }
 
 func GetMessages(c *client.Client) func(ctx context.Context, req *mcp.CallToolRequest, input GetMessagesInput) (*mcp.CallToolResult, GetMessagesOutput, error) {
@@ -49,6 +97,13 @@ func GetMessages(c *client.Client) func(ctx context.Context, req *mcp.CallToolRe
 			}, nil
 		}
 
+		if !validTextFormat(input.Format) {
+			return nil, GetMessagesOutput{
+				Success: false,
+				Message: fmt.Sprintf("Unknown format: %s", input.Format),
+			}, nil
+		}
+
 		limit := input.Limit
 		if limit <= 0 {
 			limit = 10
@@ -57,7 +112,7 @@ func GetMessages(c *client.Client) func(ctx context.Context, req *mcp.CallToolRe
 			limit = 100
 		}
 
-		inputPeer, err := resolvePeer(ctx, api, input.Chat)
+		peer, err := c.Resolve(ctx, input.Chat)
 		if err != nil {
 			return nil, GetMessagesOutput{
 				Success: false,
@@ -65,10 +120,22 @@ func GetMessages(c *client.Client) func(ctx context.Context, req *mcp.CallToolRe
 			}, nil
 		}
 
-		history, err := api.MessagesGetHistory(ctx, &tg.MessagesGetHistoryRequest{
-			Peer:  inputPeer,
-			Limit: limit,
-		})
+		if input.UnreadOnly && input.TopicID != 0 {
+			return nil, GetMessagesOutput{
+				Success: false,
+				Message: "unread_only cannot be combined with topic_id",
+			}, nil
+		}
+
+		var history *client.History
+		if input.UnreadOnly {
+			history, err = c.Unread(ctx, peer, limit)
+		} else {
+			history, err = chatHistory(ctx, c, peer, input.TopicID, client.HistoryOptions{
+				Limit:   limit,
+				Refresh: input.Refresh,
+			})
+		}
 		if err != nil {
 			return nil, GetMessagesOutput{
 				Success: false,
@@ -76,100 +143,214 @@ func GetMessages(c *client.Client) func(ctx context.Context, req *mcp.CallToolRe
 			}, nil
 		}
 
-		messages, users := extractMessagesAndUsers(history)
+		refs := newPeerRefs(c, history.Users, history.Chats)
 
-		userMap := make(map[int64]string)
-		for _, u := range users {
-			if user, ok := u.(*tg.User); ok {
-				name := user.FirstName
-				if user.LastName != "" {
-					name += " " + user.LastName
-				}
-				userMap[user.ID] = name
+		result := make([]Message, 0, len(history.Messages))
+		for _, msg := range history.Messages {
+			if m, ok := convertMessage(msg, refs, input.Format); ok {
+				result = append(result, m)
 			}
 		}
 
-		result := make([]Message, 0, len(messages))
-		for _, msg := range messages {
-			if m, ok := msg.(*tg.Message); ok {
-				fromID := int64(0)
-				fromName := ""
-				if m.FromID != nil {
-					if userPeer, ok := m.FromID.(*tg.PeerUser); ok {
-						fromID = userPeer.UserID
-						fromName = userMap[fromID]
-					}
-				}
+		output := GetMessagesOutput{
+			Success:  true,
+			Messages: result,
+		}
+		if input.UnreadOnly {
+			output.UnreadCount = history.Count
+		}
+		return nil, output, nil
+	}
+}
 
-				result = append(result, Message{
-					ID:       m.ID,
-					Text:     m.Message,
-					FromID:   fromID,
-					FromName: fromName,
-					Date:     time.Unix(int64(m.Date), 0).Format(time.RFC3339),
-					IsOut:    m.Out,
-				})
+// chatHistory reads a forum topic when topicID is set and the whole chat
+// otherwise.
+func chatHistory(ctx context.Context, c *client.Client, peer client.Peer, topicID int, opts client.HistoryOptions) (*client.History, error) {
+	switch {
+	case topicID > generalTopicID:
+		return c.Replies(ctx, peer, topicID, opts)
+	case topicID != 0:
+		return generalHistory(ctx, c, peer, opts)
+	}
+	return c.History(ctx, peer, opts)
+}
+
+// generalHistory reads the General topic of a forum. It is not a reply
+// thread, so it is taken from the chat history, leaving out messages that
+// belong to other topics. Its total count is unknown.
+func generalHistory(ctx context.Context, c *client.Client, peer client.Peer, opts client.HistoryOptions) (*client.History, error) {
+	result := &client.History{}
+	offsetID := opts.OffsetID
+	for len(result.Messages) < opts.Limit {
+		h, err := c.History(ctx, peer, client.HistoryOptions{
+			OffsetID: offsetID,
+			Limit:    opts.Limit,
+			Refresh:  opts.Refresh,
+		})
+		if err != nil {
+			return nil, err
+		}
+		result.Users = append(result.Users, h.Users...)
+		result.Chats = append(result.Chats, h.Chats...)
+
+		for _, msg := range h.Messages {
+			if !inForumTopic(msg) {
+				result.Messages = append(result.Messages, msg)
+				if len(result.Messages) == opts.Limit {
+					break
+				}
 			}
 		}
+		if len(h.Messages) < opts.Limit {
+			break
+		}
+		offsetID = h.Messages[len(h.Messages)-1].GetID()
+	}
+	return result, nil
+}
 
-		return nil, GetMessagesOutput{
-			Success:  true,
-			Messages: result,
-		}, nil
+// inForumTopic reports whether a message was posted in, or opens, a forum
+// topic other than General.
+func inForumTopic(msg tg.MessageClass) bool {
+	var replyTo tg.MessageReplyHeaderClass
+	switch m := msg.(type) {
+	case *tg.Message:
+		replyTo = m.ReplyTo
+	case *tg.MessageService:
+		if _, ok := m.Action.(*tg.MessageActionTopicCreate); ok {
+			return true
+		}
+		replyTo = m.ReplyTo
 	}
+	header, ok := replyTo.(*tg.MessageReplyHeader)
+	return ok && header.ForumTopic
 }
 
-func resolvePeer(ctx context.Context, api *tg.Client, chat string) (tg.InputPeerClass, error) {
-	username := strings.TrimPrefix(chat, "@")
+func userName(user *tg.User) string {
+	name := user.FirstName
+	if user.LastName != "" {
+		name += " " + user.LastName
+	}
+	return name
+}
 
-	resolved, err := api.ContactsResolveUsername(ctx, &tg.ContactsResolveUsernameRequest{
-		Username: username,
-	})
-	if err != nil {
-		return nil, err
+func newMessage(m *tg.Message, refs *peerRefs, textFormat string) Message {
+	msg := Message{
+		ID:         m.ID,
+		Date:       formatDate(m.Date),
+		IsOut:      m.Out,
+		PostAuthor: m.PostAuthor,
+		Views:      m.Views,
+		Forwards:   m.Forwards,
+		GroupedID:  m.GroupedID,
+		Media:      describeMedia(m.Media),
+		Reactions:  messageReactions(m),
 	}
 
-	for _, u := range resolved.Users {
-		if user, ok := u.(*tg.User); ok {
-			return &tg.InputPeerUser{
-				UserID:     user.ID,
-				AccessHash: user.AccessHash,
-			}, nil
+	setSender(&msg, m.FromID, m.PeerID, m.Out, refs)
+	if m.EditDate != 0 && !m.EditHide {
+		msg.EditDate = formatDate(m.EditDate)
+	}
+	if m.ViaBotID != 0 {
+		bot := refs.user(m.ViaBotID)
+		msg.ViaBot = &bot
+	}
+
+	if header, ok := m.ReplyTo.(*tg.MessageReplyHeader); ok {
+		reply := &ReplyTo{
+			MessageID: header.ReplyToMsgID,
+			TopID:     header.ReplyToTopID,
+			Quote:     header.QuoteText,
 		}
+		if header.ReplyToPeerID != nil {
+			chat := refs.ref(header.ReplyToPeerID)
+			reply.Chat = &chat
+		}
+		msg.ReplyTo = reply
 	}
 
-	for _, ch := range resolved.Chats {
-		switch c := ch.(type) {
-		case *tg.Chat:
-			return &tg.InputPeerChat{ChatID: c.ID}, nil
-		case *tg.Channel:
-			return &tg.InputPeerChannel{
-				ChannelID:  c.ID,
-				AccessHash: c.AccessHash,
-			}, nil
+	if fwd, ok := m.GetFwdFrom(); ok {
+		forward := &Forward{
+			Name:       fwd.FromName,
+			Date:       formatDate(fwd.Date),
+			MessageID:  fwd.ChannelPost,
+			PostAuthor: fwd.PostAuthor,
 		}
+		if fwd.FromID != nil {
+			from := refs.ref(fwd.FromID)
+			forward.From = &from
+			if forward.Name == "" {
+				forward.Name = from.Title
+			}
+		}
+		msg.ForwardedFrom = forward
+	}
+
+	applyTextFormat(&msg, m, textFormat)
+	if poll, ok := m.Media.(*tg.MessageMediaPoll); ok {
+		msg.Poll = newPoll(poll.Poll, poll.Results)
+		if msg.Text == "" {
+			msg.Text = pollText(msg.Poll)
+		}
+	}
+	return msg
+}
+
+// convertMessage converts regular and service messages, reporting false for
+// empty ones.
+func convertMessage(msg tg.MessageClass, refs *peerRefs, textFormat string) (Message, bool) {
+	switch m := msg.(type) {
+	case *tg.Message:
+		return newMessage(m, refs, textFormat), true
+	case *tg.MessageService:
+		return newServiceMessage(m, refs), true
 	}
+	return Message{}, false
+}
 
-	return nil, fmt.Errorf("could not resolve peer: %s", chat)
+// setSender fills in who sent a message. Without from_id the sender is the
+// chat itself for channel posts and incoming private messages; anonymous
+// admins and users posting as a channel come with a channel from_id.
+func setSender(msg *Message, from, peer tg.PeerClass, out bool, refs *peerRefs) {
+	if from == nil {
+		if _, ok := peer.(*tg.PeerChannel); !ok && out {
+			return
+		}
+		from = peer
+	}
+	ref := refs.ref(from)
+	msg.FromID = ref.ID
+	msg.FromName = ref.Title
+	msg.FromType = ref.Type
+}
+
+func chatRef(p client.Peer) ChatRef {
+	ref := ChatRef{
+		ID:    p.ID,
+		Type:  p.Kind,
+		Title: p.Title,
+	}
+	if len(p.Usernames) > 0 {
+		ref.Username = p.Usernames[0]
+	}
+	return ref
 }
 
-func extractMessagesAndUsers(mm tg.MessagesMessagesClass) ([]tg.MessageClass, []tg.UserClass) {
-	switch m := mm.(type) {
-	case *tg.MessagesMessages:
-		return m.Messages, m.Users
-	case *tg.MessagesMessagesSlice:
-		return m.Messages, m.Users
-	case *tg.MessagesChannelMessages:
-		return m.Messages, m.Users
-	default:
-		return nil, nil
+func extractMessages(mm tg.MessagesMessagesClass) ([]tg.MessageClass, []tg.UserClass, []tg.ChatClass) {
+	m, ok := mm.AsModified()
+	if !ok {
+		return nil, nil, nil
 	}
+	return m.GetMessages(), m.GetUsers(), m.GetChats()
 }
 
 type GetHistoryInput struct {
 	Chat     string `json:"chat"`
 	Limit    int    `json:"limit,omitempty"`
 	OffsetID int    `json:"offset_id,omitempty"`
+	TopicID  int    `json:"topic_id,omitempty"`
+	Refresh  bool   `json:"refresh,omitempty"`
+	Format   string `json:"format,omitempty"`
 }
 
 type GetHistoryOutput struct {
@@ -198,6 +379,13 @@ func GetHistory(c *client.Client) func(ctx context.Context, req *mcp.CallToolReq
 			}, nil
 		}
 
+		if !validTextFormat(input.Format) {
+			return nil, GetHistoryOutput{
+				Success: false,
+				Message: fmt.Sprintf("Unknown format: %s", input.Format),
+			}, nil
+		}
+
 		limit := input.Limit
 		if limit <= 0 {
 			limit = 100
@@ -206,7 +394,7 @@ func GetHistory(c *client.Client) func(ctx context.Context, req *mcp.CallToolReq
 			limit = 1000
 		}
 
-		inputPeer, err := resolvePeerOrDialogs(ctx, api, input.Chat)
+		peer, err := c.Resolve(ctx, input.Chat)
 		if err != nil {
 			return nil, GetHistoryOutput{
 				Success: false,
@@ -214,10 +402,10 @@ func GetHistory(c *client.Client) func(ctx context.Context, req *mcp.CallToolReq
 			}, nil
 		}
 
-		history, err := api.MessagesGetHistory(ctx, &tg.MessagesGetHistoryRequest{
-			Peer:     inputPeer,
-			Limit:    limit,
+		history, err := chatHistory(ctx, c, peer, input.TopicID, client.HistoryOptions{
 			OffsetID: input.OffsetID,
+			Limit:    limit,
+			Refresh:  input.Refresh,
 		})
 		if err != nil {
 			return nil, GetHistoryOutput{
@@ -226,41 +414,15 @@ func GetHistory(c *client.Client) func(ctx context.Context, req *mcp.CallToolReq
 			}, nil
 		}
 
-		messages, users := extractMessagesAndUsers(history)
-		total := extractTotalCount(history)
-
-		userMap := make(map[int64]string)
-		for _, u := range users {
-			if user, ok := u.(*tg.User); ok {
-				name := user.FirstName
-				if user.LastName != "" {
-					name += " " + user.LastName
-				}
-				userMap[user.ID] = name
-			}
-		}
+		messages := history.Messages
+		total := history.Count
+		refs := newPeerRefs(c, history.Users, history.Chats)
 
 		result := make([]Message, 0, len(messages))
 		var lastID int
 		for _, msg := range messages {
-			if m, ok := msg.(*tg.Message); ok {
-				fromID := int64(0)
-				fromName := ""
-				if m.FromID != nil {
-					if userPeer, ok := m.FromID.(*tg.PeerUser); ok {
-						fromID = userPeer.UserID
-						fromName = userMap[fromID]
-					}
-				}
-
-				result = append(result, Message{
-					ID:       m.ID,
-					Text:     m.Message,
-					FromID:   fromID,
-					FromName: fromName,
-					Date:     time.Unix(int64(m.Date), 0).Format(time.RFC3339),
-					IsOut:    m.Out,
-				})
+			if m, ok := convertMessage(msg, refs, input.Format); ok {
+				result = append(result, m)
 				lastID = m.ID
 			}
 		}
@@ -281,83 +443,14 @@ func GetHistory(c *client.Client) func(ctx context.Context, req *mcp.CallToolReq
 	}
 }
 
-func resolvePeerOrDialogs(ctx context.Context, api *tg.Client, chat string) (tg.InputPeerClass, error) {
-	if chatID, err := parseIntID(chat); err == nil {
-		dialogs, err := api.MessagesGetDialogs(ctx, &tg.MessagesGetDialogsRequest{
-			OffsetPeer: &tg.InputPeerEmpty{},
-			Limit:      200,
-		})
-		if err != nil {
-			return nil, err
-		}
-
-		var users []tg.UserClass
-		var chats []tg.ChatClass
-		switch d := dialogs.(type) {
-		case *tg.MessagesDialogs:
-			users = d.Users
-			chats = d.Chats
-		case *tg.MessagesDialogsSlice:
-			users = d.Users
-			chats = d.Chats
-		}
-
-		for _, u := range users {
-			if user, ok := u.(*tg.User); ok && user.ID == chatID {
-				return &tg.InputPeerUser{UserID: user.ID, AccessHash: user.AccessHash}, nil
-			}
-		}
-
-		for _, ch := range chats {
-			switch c := ch.(type) {
-			case *tg.Chat:
-				if c.ID == chatID {
-					return &tg.InputPeerChat{ChatID: c.ID}, nil
-				}
-			case *tg.Channel:
-				if c.ID == chatID {
-					return &tg.InputPeerChannel{ChannelID: c.ID, AccessHash: c.AccessHash}, nil
-				}
-			}
-		}
-
-		return nil, fmt.Errorf("chat %d not found in dialogs", chatID)
-	}
-
-	return resolvePeer(ctx, api, chat)
-}
-
-func parseIntID(s string) (int64, error) {
-	return parseInt64(s)
-}
-
-func parseInt64(s string) (int64, error) {
-	var id int64
-	_, err := fmt.Sscanf(s, "%d", &id)
-	return id, err
-}
-
-func extractTotalCount(mm tg.MessagesMessagesClass) int {
-	switch m := mm.(type) {
-	case *tg.MessagesMessages:
-		return len(m.Messages)
-	case *tg.MessagesMessagesSlice:
-		return m.Count
-	case *tg.MessagesChannelMessages:
-		return m.Count
-	default:
-		return 0
-	}
-}
-
 func RegisterMessagesTools(server *mcp.Server, c *client.Client) {
-	mcp.AddTool(server, &mcp.Tool{
+	addUserTool(server, c, &mcp.Tool{
 		Name:        "get_messages",
-		Description: "Get recent messages from a Telegram chat (up to 100)",
+		Description: "Get recent messages from a Telegram chat (up to 100). Served from the local archive; set refresh=true to re-read them from Telegram. topic_id limits the result to a forum topic (read live, not archived; General, topic 1, is filtered from the chat history instead). unread_only=true returns only incoming messages after the read position, with the chat's unread_count. format: plain (default), markdown or html renders text formatting and links; entities returns the raw entity list with UTF-16 offsets.",
 	}, GetMessages(c))
 
-	mcp.AddTool(server, &mcp.Tool{
+	addUserTool(server, c, &mcp.Tool{
 		Name:        "get_history",
-		Description: "Get chat history with pagination. Use limit (up to 1000) and offset_id for chunked loading. Returns next_offset for next chunk.",
+		Description: "Get chat history with pagination. Use limit (up to 1000) and offset_id for chunked loading. Returns next_offset for next chunk. Served from the local archive; set refresh=true to re-read the window from Telegram. Supports the same topic_id and format values as get_messages.",
 	}, GetHistory(c))
 }
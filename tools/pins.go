@@ -0,0 +1,294 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gotd/td/tg"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"tg-mcp/client"
+)
+
+type PinMessageInput struct {
+	Chat      string `json:"chat"`
+	MessageID int    `json:"message_id"`
+	Silent    bool   `json:"silent,omitempty"`
+	ForMeOnly bool   `json:"for_me_only,omitempty"`
+}
+
+type PinMessageOutput struct {
+	Success bool   `json:"success"`
+	Message string `json:"message,omitempty"`
+}
+
+func PinMessage(c *client.Client) func(ctx context.Context, req *mcp.CallToolRequest, input PinMessageInput) (*mcp.CallToolResult, PinMessageOutput, error) {
+	return func(ctx context.Context, req *mcp.CallToolRequest, input PinMessageInput) (*mcp.CallToolResult, PinMessageOutput, error) {
+		if !c.IsAuthorized() {
+			return nil, PinMessageOutput{
+				Success: false,
+				Message: "Not authorized",
+			}, nil
+		}
+
+		api := c.API()
+		if api == nil {
+			return nil, PinMessageOutput{
+				Success: false,
+				Message: "Client is not running",
+			}, nil
+		}
+
+		inputPeer, err := c.ResolvePeer(ctx, input.Chat)
+		if err != nil {
+			return nil, PinMessageOutput{
+				Success: false,
+				Message: fmt.Sprintf("Failed to resolve chat: %v", err),
+			}, nil
+		}
+
+		_, err = api.MessagesUpdatePinnedMessage(ctx, &tg.MessagesUpdatePinnedMessageRequest{
+			Peer:      inputPeer,
+			ID:        input.MessageID,
+			Silent:    input.Silent,
+			PmOneside: input.ForMeOnly,
+		})
+		if err != nil {
+			return nil, PinMessageOutput{
+				Success: false,
+				Message: fmt.Sprintf("Failed to pin message: %v", err),
+			}, nil
+		}
+
+		return nil, PinMessageOutput{
+			Success: true,
+			Message: "Message pinned",
+		}, nil
+	}
+}
+
+type UnpinMessageInput struct {
+	Chat      string `json:"chat"`
+	MessageID int    `json:"message_id"`
+}
+
+type UnpinMessageOutput struct {
+	Success bool   `json:"success"`
+	Message string `json:"message,omitempty"`
+}
+
+func UnpinMessage(c *client.Client) func(ctx context.Context, req *mcp.CallToolRequest, input UnpinMessageInput) (*mcp.CallToolResult, UnpinMessageOutput, error) {
+	return func(ctx context.Context, req *mcp.CallToolRequest, input UnpinMessageInput) (*mcp.CallToolResult, UnpinMessageOutput, error) {
+		if !c.IsAuthorized() {
+			return nil, UnpinMessageOutput{
+				Success: false,
+				Message: "Not authorized",
+			}, nil
+		}
+
+		api := c.API()
+		if api == nil {
+			return nil, UnpinMessageOutput{
+				Success: false,
+				Message: "Client is not running",
+			}, nil
+		}
+
+		inputPeer, err := c.ResolvePeer(ctx, input.Chat)
+		if err != nil {
+			return nil, UnpinMessageOutput{
+				Success: false,
+				Message: fmt.Sprintf("Failed to resolve chat: %v", err),
+			}, nil
+		}
+
+		_, err = api.MessagesUpdatePinnedMessage(ctx, &tg.MessagesUpdatePinnedMessageRequest{
+			Peer:  inputPeer,
+			ID:    input.MessageID,
+			Unpin: true,
+		})
+		if err != nil {
+			return nil, UnpinMessageOutput{
+				Success: false,
+				Message: fmt.Sprintf("Failed to unpin message: %v", err),
+			}, nil
+		}
+
+		return nil, UnpinMessageOutput{
+			Success: true,
+			Message: "Message unpinned",
+		}, nil
+	}
+}
+
+type UnpinAllInput struct {
+	Chat    string `json:"chat"`
+	TopicID int    `json:"topic_id,omitempty"`
+}
+
+type UnpinAllOutput struct {
+	Success bool   `json:"success"`
+	Message string `json:"message,omitempty"`
+}
+
+func UnpinAll(c *client.Client) func(ctx context.Context, req *mcp.CallToolRequest, input UnpinAllInput) (*mcp.CallToolResult, UnpinAllOutput, error) {
+	return func(ctx context.Context, req *mcp.CallToolRequest, input UnpinAllInput) (*mcp.CallToolResult, UnpinAllOutput, error) {
+		if !c.IsAuthorized() {
+			return nil, UnpinAllOutput{
+				Success: false,
+				Message: "Not authorized",
+			}, nil
+		}
+
+		api := c.API()
+		if api == nil {
+			return nil, UnpinAllOutput{
+				Success: false,
+				Message: "Client is not running",
+			}, nil
+		}
+
+		inputPeer, err := c.ResolvePeer(ctx, input.Chat)
+		if err != nil {
+			return nil, UnpinAllOutput{
+				Success: false,
+				Message: fmt.Sprintf("Failed to resolve chat: %v", err),
+			}, nil
+		}
+
+		// Messages are unpinned in batches; a non-zero offset means there
+		// are more to go.
+		for {
+			affected, err := api.MessagesUnpinAllMessages(ctx, &tg.MessagesUnpinAllMessagesRequest{
+				Peer:     inputPeer,
+				TopMsgID: input.TopicID,
+			})
+			if err != nil {
+				return nil, UnpinAllOutput{
+					Success: false,
+					Message: fmt.Sprintf("Failed to unpin messages: %v", err),
+				}, nil
+			}
+			if affected.Offset == 0 {
+				break
+			}
+		}
+
+		return nil, UnpinAllOutput{
+			Success: true,
+			Message: "All messages unpinned",
+		}, nil
+	}
+}
+
+type GetPinnedMessagesInput struct {
+	Chat    string `json:"chat"`
+	TopicID int    `json:"topic_id,omitempty"`
+	Limit   int    `json:"limit,omitempty"`
+	Format  string `json:"format,omitempty"`
+}
+
+type GetPinnedMessagesOutput struct {
+	Success  bool      `json:"success"`
+	Messages []Message `json:"messages,omitempty"`
+	Total    int       `json:"total,omitempty"`
+	Message  string    `json:"message,omitempty"`
+}
+
+func GetPinnedMessages(c *client.Client) func(ctx context.Context, req *mcp.CallToolRequest, input GetPinnedMessagesInput) (*mcp.CallToolResult, GetPinnedMessagesOutput, error) {
+	return func(ctx context.Context, req *mcp.CallToolRequest, input GetPinnedMessagesInput) (*mcp.CallToolResult, GetPinnedMessagesOutput, error) {
+		if !c.IsAuthorized() {
+			return nil, GetPinnedMessagesOutput{
+				Success: false,
+				Message: "Not authorized",
+			}, nil
+		}
+
+		api := c.API()
+		if api == nil {
+			return nil, GetPinnedMessagesOutput{
+				Success: false,
+				Message: "Client is not running",
+			}, nil
+		}
+
+		if !validTextFormat(input.Format) {
+			return nil, GetPinnedMessagesOutput{
+				Success: false,
+				Message: fmt.Sprintf("Unknown format: %s", input.Format),
+			}, nil
+		}
+
+		limit := input.Limit
+		if limit <= 0 {
+			limit = 20
+		}
+		if limit > 100 {
+			limit = 100
+		}
+
+		inputPeer, err := c.ResolvePeer(ctx, input.Chat)
+		if err != nil {
+			return nil, GetPinnedMessagesOutput{
+				Success: false,
+				Message: fmt.Sprintf("Failed to resolve chat: %v", err),
+			}, nil
+		}
+
+		resp, err := api.MessagesSearch(ctx, &tg.MessagesSearchRequest{
+			Peer:     inputPeer,
+			Filter:   &tg.InputMessagesFilterPinned{},
+			TopMsgID: input.TopicID,
+			Limit:    limit,
+		})
+		if err != nil {
+			return nil, GetPinnedMessagesOutput{
+				Success: false,
+				Message: fmt.Sprintf("Failed to get pinned messages: %v", err),
+			}, nil
+		}
+
+		messages, users, chats := extractMessages(resp)
+		refs := newPeerRefs(c, users, chats)
+
+		output := GetPinnedMessagesOutput{
+			Success: true,
+			Total:   len(messages),
+		}
+		for _, msg := range messages {
+			if m, ok := convertMessage(msg, refs, input.Format); ok {
+				output.Messages = append(output.Messages, m)
+			}
+		}
+		switch r := resp.(type) {
+		case *tg.MessagesMessagesSlice:
+			output.Total = r.Count
+		case *tg.MessagesChannelMessages:
+			output.Total = r.Count
+		}
+
+		return nil, output, nil
+	}
+}
+
+func RegisterPinsTools(server *mcp.Server, c *client.Client) {
+	addTool(server, c, &mcp.Tool{
+		Name:        "pin_message",
+		Description: "Pin a message in a chat. silent=true pins without notifying members; for_me_only=true pins it only on your side of a private chat.",
+	}, PinMessage(c))
+
+	addTool(server, c, &mcp.Tool{
+		Name:        "unpin_message",
+		Description: "Unpin a pinned message.",
+	}, UnpinMessage(c))
+
+	addTool(server, c, &mcp.Tool{
+		Name:        "unpin_all",
+		Description: "Unpin every pinned message in a chat, or in a single forum topic with topic_id.",
+	}, UnpinAll(c))
+
+	addUserTool(server, c, &mcp.Tool{
+		Name:        "get_pinned_messages",
+		Description: "Get the pinned messages of a chat, newest first (up to 100), optionally in a forum topic with topic_id. Supports the same format values as get_messages.",
+	}, GetPinnedMessages(c))
+}
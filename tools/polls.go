@@ -0,0 +1,504 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gotd/td/telegram/message"
+	"github.com/gotd/td/tg"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"tg-mcp/client"
+)
+
+const (
+	minPollOptions = 2
+	maxPollOptions = 10
+)
+
+// Poll describes a poll or quiz. Answers are referred to by their index,
+// starting at 0.
+type Poll struct {
+	Question       string       `json:"question"`
+	Answers        []PollAnswer `json:"answers"`
+	Quiz           bool         `json:"quiz,omitempty"`
+	MultipleChoice bool         `json:"multiple_choice,omitempty"`
+	PublicVoters   bool         `json:"public_voters,omitempty"`
+	Closed         bool         `json:"closed,omitempty"`
+	CloseDate      string       `json:"close_date,omitempty"`
+	TotalVoters    int          `json:"total_voters"`
+	Solution       string       `json:"solution,omitempty"`
+}
+
+type PollAnswer struct {
+	Index   int    `json:"index"`
+	Text    string `json:"text"`
+	Voters  int    `json:"voters"`
+	Chosen  bool   `json:"chosen,omitempty"`
+	Correct bool   `json:"correct,omitempty"`
+}
+
+func newPoll(poll tg.Poll, results tg.PollResults) *Poll {
+	p := &Poll{
+		Question:       poll.Question.Text,
+		Quiz:           poll.Quiz,
+		MultipleChoice: poll.MultipleChoice,
+		PublicVoters:   poll.PublicVoters,
+		Closed:         poll.Closed,
+		TotalVoters:    results.TotalVoters,
+		Solution:       results.Solution,
+	}
+	if poll.CloseDate != 0 {
+		p.CloseDate = formatDate(poll.CloseDate)
+	}
+
+	for i, a := range poll.Answers {
+		answer := PollAnswer{Index: i, Text: a.Text.Text}
+		for _, r := range results.Results {
+			if bytes.Equal(r.Option, a.Option) {
+				answer.Voters = r.Voters
+				answer.Chosen = r.Chosen
+				answer.Correct = r.Correct
+			}
+		}
+		p.Answers = append(p.Answers, answer)
+	}
+	return p
+}
+
+// pollText renders a poll as message text, as polls have none of their own.
+func pollText(p *Poll) string {
+	var b strings.Builder
+	b.WriteString(p.Question)
+	for _, a := range p.Answers {
+		fmt.Fprintf(&b, "\n%d. %s (%d)", a.Index, a.Text, a.Voters)
+	}
+	return b.String()
+}
+
+// optionIndex returns the index of the answer with the given option bytes.
+func optionIndex(poll tg.Poll, option []byte) int {
+	for i, a := range poll.Answers {
+		if bytes.Equal(a.Option, option) {
+			return i
+		}
+	}
+	return -1
+}
+
+func messagePoll(ctx context.Context, c *client.Client, peer client.Peer, msgID int) (*tg.MessageMediaPoll, error) {
+	msg, err := c.GetMessage(ctx, peer, msgID)
+	if err != nil {
+		return nil, err
+	}
+	m, ok := msg.(*tg.Message)
+	if !ok {
+		return nil, fmt.Errorf("message %d is not a poll", msgID)
+	}
+	poll, ok := m.Media.(*tg.MessageMediaPoll)
+	if !ok {
+		return nil, fmt.Errorf("message %d is not a poll", msgID)
+	}
+	return poll, nil
+}
+
+// updatedPoll applies the poll update contained in updates, if any, to poll.
+func updatedPoll(updates tg.UpdatesClass, poll *tg.MessageMediaPoll) {
+	u, ok := updates.(*tg.Updates)
+	if !ok {
+		return
+	}
+	for _, update := range u.Updates {
+		mp, ok := update.(*tg.UpdateMessagePoll)
+		if !ok || mp.PollID != poll.Poll.ID {
+			continue
+		}
+		if p, ok := mp.GetPoll(); ok {
+			poll.Poll = p
+		}
+		poll.Results = mp.Results
+	}
+}
+
+type SendPollInput struct {
+	Chat           string   `json:"chat"`
+	Question       string   `json:"question"`
+	Options        []string `json:"options"`
+	Quiz           bool     `json:"quiz,omitempty"`
+	CorrectOptions []int    `json:"correct_options,omitempty"`
+	MultipleChoice bool     `json:"multiple_choice,omitempty"`
+	PublicVoters   bool     `json:"public_voters,omitempty"`
+	CloseDate      string   `json:"close_date,omitempty"`
+	Solution       string   `json:"solution,omitempty"`
+	ParseMode      string   `json:"parse_mode,omitempty"`
+	TopicID        int      `json:"topic_id,omitempty"`
+	ReplyTo        int      `json:"reply_to,omitempty"`
+	ScheduleDate   string   `json:"schedule_date,omitempty"`
+}
+
+type SendPollOutput struct {
+	Success   bool   `json:"success"`
+	MessageID int    `json:"message_id,omitempty"`
+	Message   string `json:"message,omitempty"`
+}
+
+func SendPoll(c *client.Client) func(ctx context.Context, req *mcp.CallToolRequest, input SendPollInput) (*mcp.CallToolResult, SendPollOutput, error) {
+	return func(ctx context.Context, req *mcp.CallToolRequest, input SendPollInput) (*mcp.CallToolResult, SendPollOutput, error) {
+		if !c.IsAuthorized() {
+			return nil, SendPollOutput{
+				Success: false,
+				Message: "Not authorized",
+			}, nil
+		}
+
+		sender := c.Sender()
+		if sender == nil {
+			return nil, SendPollOutput{
+				Success: false,
+				Message: "Client is not running",
+			}, nil
+		}
+
+		if input.ScheduleDate != "" && c.IsBot() {
+			return nil, SendPollOutput{
+				Success: false,
+				Message: "Bots cannot schedule messages",
+			}, nil
+		}
+
+		poll, err := buildPoll(c, input)
+		if err != nil {
+			return nil, SendPollOutput{
+				Success: false,
+				Message: fmt.Sprintf("Invalid poll: %v", err),
+			}, nil
+		}
+
+		when, err := parseScheduleDate(input.ScheduleDate, time.Now())
+		if err != nil {
+			return nil, SendPollOutput{
+				Success: false,
+				Message: fmt.Sprintf("Invalid schedule_date: %v", err),
+			}, nil
+		}
+
+		inputPeer, err := c.ResolvePeer(ctx, input.Chat)
+		if err != nil {
+			return nil, SendPollOutput{
+				Success: false,
+				Message: fmt.Sprintf("Failed to resolve chat: %v", err),
+			}, nil
+		}
+
+		builder := &sender.To(inputPeer).Builder
+		if id := threadReply(input.ReplyTo, input.TopicID); id != 0 {
+			builder = builder.Reply(id)
+		}
+
+		updates, err := schedule(builder, when).Media(ctx, poll)
+		if err != nil {
+			return nil, SendPollOutput{
+				Success: false,
+				Message: fmt.Sprintf("Failed to send poll: %v", err),
+			}, nil
+		}
+
+		return nil, SendPollOutput{
+			Success:   true,
+			MessageID: extractMessageID(updates),
+			Message:   sentMessage("Poll sent successfully", when),
+		}, nil
+	}
+}
+
+func buildPoll(c *client.Client, input SendPollInput) (*message.PollBuilder, error) {
+	if input.Question == "" {
+		return nil, fmt.Errorf("question is required")
+	}
+	if len(input.Options) < minPollOptions || len(input.Options) > maxPollOptions {
+		return nil, fmt.Errorf("provide between %d and %d options", minPollOptions, maxPollOptions)
+	}
+
+	correct := make(map[int]bool, len(input.CorrectOptions))
+	for _, i := range input.CorrectOptions {
+		if i < 0 || i >= len(input.Options) {
+			return nil, fmt.Errorf("correct option %d is out of range", i)
+		}
+		correct[i] = true
+	}
+	switch {
+	case input.Quiz && input.MultipleChoice:
+		return nil, fmt.Errorf("a quiz cannot be multiple_choice")
+	case input.Quiz && len(correct) == 0:
+		return nil, fmt.Errorf("a quiz needs correct_options")
+	case input.Quiz && len(correct) > 1:
+		return nil, fmt.Errorf("a quiz has exactly one correct option, got %d in correct_options", len(correct))
+	case !input.Quiz && len(correct) > 0:
+		return nil, fmt.Errorf("correct_options are only allowed in a quiz")
+	case !input.Quiz && input.Solution != "":
+		return nil, fmt.Errorf("solution is only allowed in a quiz")
+	}
+
+	answers := make([]message.PollAnswerOption, 0, len(input.Options))
+	for i, text := range input.Options {
+		if correct[i] {
+			answers = append(answers, message.CorrectPollAnswer(text))
+		} else {
+			answers = append(answers, message.PollAnswer(text))
+		}
+	}
+
+	poll := message.Poll(input.Question, answers[0], answers[1], answers[2:]...).
+		PublicVoters(input.PublicVoters).
+		MultipleChoice(input.MultipleChoice)
+
+	if input.CloseDate != "" {
+		closeDate, err := parseScheduleDate(input.CloseDate, time.Now())
+		if err != nil {
+			return nil, fmt.Errorf("close_date: %w", err)
+		}
+		poll = poll.CloseDate(closeDate)
+	}
+
+	if input.Solution != "" {
+		solution, err := styledText(c, input.Solution, input.ParseMode)
+		if err != nil {
+			return nil, fmt.Errorf("solution: %w", err)
+		}
+		poll = poll.StyledExplanation(solution)
+	}
+	return poll, nil
+}
+
+type VotePollInput struct {
+	Chat      string `json:"chat"`
+	MessageID int    `json:"message_id"`
+	Options   []int  `json:"options,omitempty"`
+}
+
+type VotePollOutput struct {
+	Success bool   `json:"success"`
+	Poll    *Poll  `json:"poll,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+func VotePoll(c *client.Client) func(ctx context.Context, req *mcp.CallToolRequest, input VotePollInput) (*mcp.CallToolResult, VotePollOutput, error) {
+	return func(ctx context.Context, req *mcp.CallToolRequest, input VotePollInput) (*mcp.CallToolResult, VotePollOutput, error) {
+		if !c.IsAuthorized() {
+			return nil, VotePollOutput{
+				Success: false,
+				Message: "Not authorized",
+			}, nil
+		}
+
+		api := c.API()
+		if api == nil {
+			return nil, VotePollOutput{
+				Success: false,
+				Message: "Client is not running",
+			}, nil
+		}
+
+		peer, err := c.Resolve(ctx, input.Chat)
+		if err != nil {
+			return nil, VotePollOutput{
+				Success: false,
+				Message: fmt.Sprintf("Failed to resolve chat: %v", err),
+			}, nil
+		}
+
+		poll, err := messagePoll(ctx, c, peer, input.MessageID)
+		if err != nil {
+			return nil, VotePollOutput{
+				Success: false,
+				Message: fmt.Sprintf("Failed to get poll: %v", err),
+			}, nil
+		}
+
+		options := make([][]byte, 0, len(input.Options))
+		for _, i := range input.Options {
+			if i < 0 || i >= len(poll.Poll.Answers) {
+				return nil, VotePollOutput{
+					Success: false,
+					Message: fmt.Sprintf("Option %d is out of range", i),
+				}, nil
+			}
+			options = append(options, poll.Poll.Answers[i].Option)
+		}
+
+		updates, err := api.MessagesSendVote(ctx, &tg.MessagesSendVoteRequest{
+			Peer:    peer.InputPeer(),
+			MsgID:   input.MessageID,
+			Options: options,
+		})
+		if err != nil {
+			return nil, VotePollOutput{
+				Success: false,
+				Message: fmt.Sprintf("Failed to vote: %v", err),
+			}, nil
+		}
+		updatedPoll(updates, poll)
+
+		msg := "Vote cast"
+		if len(options) == 0 {
+			msg = "Vote retracted"
+		}
+		return nil, VotePollOutput{
+			Success: true,
+			Poll:    newPoll(poll.Poll, poll.Results),
+			Message: msg,
+		}, nil
+	}
+}
+
+type GetPollResultsInput struct {
+	Chat      string `json:"chat"`
+	MessageID int    `json:"message_id"`
+	Voters    bool   `json:"voters,omitempty"`
+	Limit     int    `json:"limit,omitempty"`
+	Cursor    string `json:"cursor,omitempty"`
+}
+
+type PollVoter struct {
+	From    ChatRef `json:"from"`
+	Options []int   `json:"options,omitempty"`
+	Date    string  `json:"date"`
+}
+
+type GetPollResultsOutput struct {
+	Success    bool        `json:"success"`
+	Poll       *Poll       `json:"poll,omitempty"`
+	Voters     []PollVoter `json:"voters,omitempty"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+	Message    string      `json:"message,omitempty"`
+}
+
+func GetPollResults(c *client.Client) func(ctx context.Context, req *mcp.CallToolRequest, input GetPollResultsInput) (*mcp.CallToolResult, GetPollResultsOutput, error) {
+	return func(ctx context.Context, req *mcp.CallToolRequest, input GetPollResultsInput) (*mcp.CallToolResult, GetPollResultsOutput, error) {
+		if !c.IsAuthorized() {
+			return nil, GetPollResultsOutput{
+				Success: false,
+				Message: "Not authorized",
+			}, nil
+		}
+
+		api := c.API()
+		if api == nil {
+			return nil, GetPollResultsOutput{
+				Success: false,
+				Message: "Client is not running",
+			}, nil
+		}
+
+		limit := input.Limit
+		if limit <= 0 {
+			limit = 50
+		}
+		if limit > 100 {
+			limit = 100
+		}
+
+		peer, err := c.Resolve(ctx, input.Chat)
+		if err != nil {
+			return nil, GetPollResultsOutput{
+				Success: false,
+				Message: fmt.Sprintf("Failed to resolve chat: %v", err),
+			}, nil
+		}
+
+		poll, err := messagePoll(ctx, c, peer, input.MessageID)
+		if err != nil {
+			return nil, GetPollResultsOutput{
+				Success: false,
+				Message: fmt.Sprintf("Failed to get poll: %v", err),
+			}, nil
+		}
+
+		// The results attached to the message may be stale.
+		updates, err := api.MessagesGetPollResults(ctx, &tg.MessagesGetPollResultsRequest{
+			Peer:  peer.InputPeer(),
+			MsgID: input.MessageID,
+		})
+		if err != nil {
+			return nil, GetPollResultsOutput{
+				Success: false,
+				Message: fmt.Sprintf("Failed to get poll results: %v", err),
+			}, nil
+		}
+		updatedPoll(updates, poll)
+
+		output := GetPollResultsOutput{
+			Success: true,
+			Poll:    newPoll(poll.Poll, poll.Results),
+		}
+		if !input.Voters {
+			return nil, output, nil
+		}
+
+		if !poll.Poll.PublicVoters {
+			return nil, GetPollResultsOutput{
+				Success: false,
+				Poll:    output.Poll,
+				Message: "Voters of an anonymous poll cannot be listed",
+			}, nil
+		}
+
+		request := &tg.MessagesGetPollVotesRequest{
+			Peer:  peer.InputPeer(),
+			ID:    input.MessageID,
+			Limit: limit,
+		}
+		if input.Cursor != "" {
+			request.SetOffset(input.Cursor)
+		}
+
+		votes, err := api.MessagesGetPollVotes(ctx, request)
+		if err != nil {
+			return nil, GetPollResultsOutput{
+				Success: false,
+				Poll:    output.Poll,
+				Message: fmt.Sprintf("Failed to get voters: %v", err),
+			}, nil
+		}
+
+		refs := newPeerRefs(c, votes.Users, votes.Chats)
+		for _, v := range votes.Votes {
+			voter := PollVoter{
+				From: refs.ref(v.GetPeer()),
+				Date: formatDate(v.GetDate()),
+			}
+			switch v := v.(type) {
+			case *tg.MessagePeerVote:
+				voter.Options = []int{optionIndex(poll.Poll, v.Option)}
+			case *tg.MessagePeerVoteMultiple:
+				for _, option := range v.Options {
+					voter.Options = append(voter.Options, optionIndex(poll.Poll, option))
+				}
+			}
+			output.Voters = append(output.Voters, voter)
+		}
+		output.NextCursor = votes.NextOffset
+
+		return nil, output, nil
+	}
+}
+
+func RegisterPollsTools(server *mcp.Server, c *client.Client) {
+	addTool(server, c, &mcp.Tool{
+		Name:        "send_poll",
+		Description: "Send a poll with 2-10 options. quiz=true makes it a quiz: give the 0-based index of its one correct answer in correct_options and an optional solution (parse_mode applies to it) shown after answering. Optional multiple_choice (polls only, not quizzes), public_voters, close_date (RFC3339 or \"in 1h\"), topic_id, reply_to and schedule_date (not available to bots).",
+	}, SendPoll(c))
+
+	addUserTool(server, c, &mcp.Tool{
+		Name:        "vote_poll",
+		Description: "Vote in a poll by 0-based answer indexes; pass several only for multiple-choice polls, or none to retract your vote. Returns the updated results.",
+	}, VotePoll(c))
+
+	addUserTool(server, c, &mcp.Tool{
+		Name:        "get_poll_results",
+		Description: "Get the current results of a poll. voters=true also lists who voted for what in non-anonymous polls; pass next_cursor as cursor for more voters.",
+	}, GetPollResults(c))
+}
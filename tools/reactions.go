@@ -0,0 +1,408 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gotd/td/tg"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"tg-mcp/client"
+)
+
+// Reaction is a reaction on a message: a standard emoji, a custom emoji
+// identified by its document ID, or a paid star reaction.
+type Reaction struct {
+	Emoji         string `json:"emoji,omitempty"`
+	CustomEmojiID int64  `json:"custom_emoji_id,omitempty"`
+	Paid          bool   `json:"paid,omitempty"`
+	Count         int    `json:"count,omitempty"`
+	Chosen        bool   `json:"chosen,omitempty"`
+}
+
+func newReaction(r tg.ReactionClass) Reaction {
+	switch r := r.(type) {
+	case *tg.ReactionEmoji:
+		return Reaction{Emoji: r.Emoticon}
+	case *tg.ReactionCustomEmoji:
+		return Reaction{CustomEmojiID: r.DocumentID}
+	case *tg.ReactionPaid:
+		return Reaction{Paid: true}
+	}
+	return Reaction{}
+}
+
+func messageReactions(m *tg.Message) []Reaction {
+	reactions, ok := m.GetReactions()
+	if !ok {
+		return nil
+	}
+	result := make([]Reaction, 0, len(reactions.Results))
+	for _, rc := range reactions.Results {
+		r := newReaction(rc.Reaction)
+		r.Count = rc.Count
+		_, r.Chosen = rc.GetChosenOrder()
+		result = append(result, r)
+	}
+	return result
+}
+
+// inputReaction builds the reaction named by an emoji or a custom emoji ID,
+// returning nil if neither is set.
+func inputReaction(emoji string, customEmojiID int64) (tg.ReactionClass, error) {
+	switch {
+	case emoji != "" && customEmojiID != 0:
+		return nil, fmt.Errorf("provide either emoji or custom_emoji_id, not both")
+	case emoji != "":
+		return &tg.ReactionEmoji{Emoticon: emoji}, nil
+	case customEmojiID != 0:
+		return &tg.ReactionCustomEmoji{DocumentID: customEmojiID}, nil
+	}
+	return nil, nil
+}
+
+func sameReaction(a, b tg.ReactionClass) bool {
+	return newReaction(a) == newReaction(b)
+}
+
+// chosenReactions returns the reactions the current user has put on a
+// message, in the order they were chosen.
+func chosenReactions(ctx context.Context, c *client.Client, peer client.Peer, msgID int) ([]tg.ReactionClass, error) {
+	msg, err := c.GetMessage(ctx, peer, msgID)
+	if err != nil {
+		return nil, err
+	}
+	m, ok := msg.(*tg.Message)
+	if !ok {
+		return nil, nil
+	}
+	reactions, ok := m.GetReactions()
+	if !ok {
+		return nil, nil
+	}
+
+	chosen := make([]tg.ReactionClass, len(reactions.Results))
+	n := 0
+	for _, rc := range reactions.Results {
+		if order, ok := rc.GetChosenOrder(); ok && order < len(chosen) {
+			chosen[order] = rc.Reaction
+			n++
+		}
+	}
+	result := make([]tg.ReactionClass, 0, n)
+	for _, r := range chosen {
+		if r != nil {
+			result = append(result, r)
+		}
+	}
+	return result, nil
+}
+
+type SendReactionInput struct {
+	Chat          string `json:"chat"`
+	MessageID     int    `json:"message_id"`
+	Emoji         string `json:"emoji,omitempty"`
+	CustomEmojiID int64  `json:"custom_emoji_id,omitempty"`
+	Add           bool   `json:"add,omitempty"`
+	Big           bool   `json:"big,omitempty"`
+}
+
+type SendReactionOutput struct {
+	Success bool   `json:"success"`
+	Message string `json:"message,omitempty"`
+}
+
+func SendReaction(c *client.Client) func(ctx context.Context, req *mcp.CallToolRequest, input SendReactionInput) (*mcp.CallToolResult, SendReactionOutput, error) {
+	return func(ctx context.Context, req *mcp.CallToolRequest, input SendReactionInput) (*mcp.CallToolResult, SendReactionOutput, error) {
+		if !c.IsAuthorized() {
+			return nil, SendReactionOutput{
+				Success: false,
+				Message: "Not authorized",
+			}, nil
+		}
+
+		api := c.API()
+		if api == nil {
+			return nil, SendReactionOutput{
+				Success: false,
+				Message: "Client is not running",
+			}, nil
+		}
+
+		reaction, err := inputReaction(input.Emoji, input.CustomEmojiID)
+		if err == nil && reaction == nil {
+			err = fmt.Errorf("provide emoji or custom_emoji_id")
+		}
+		if err != nil {
+			return nil, SendReactionOutput{
+				Success: false,
+				Message: fmt.Sprintf("Invalid reaction: %v", err),
+			}, nil
+		}
+
+		peer, err := c.Resolve(ctx, input.Chat)
+		if err != nil {
+			return nil, SendReactionOutput{
+				Success: false,
+				Message: fmt.Sprintf("Failed to resolve chat: %v", err),
+			}, nil
+		}
+
+		reactions := []tg.ReactionClass{reaction}
+		if input.Add {
+			chosen, err := chosenReactions(ctx, c, peer, input.MessageID)
+			if err != nil {
+				return nil, SendReactionOutput{
+					Success: false,
+					Message: fmt.Sprintf("Failed to get message: %v", err),
+				}, nil
+			}
+			reactions = chosen
+			if !containsReaction(chosen, reaction) {
+				reactions = append(reactions, reaction)
+			}
+		}
+
+		request := &tg.MessagesSendReactionRequest{
+			Peer:        peer.InputPeer(),
+			MsgID:       input.MessageID,
+			Big:         input.Big,
+			AddToRecent: true,
+		}
+		request.SetReaction(reactions)
+
+		if _, err := api.MessagesSendReaction(ctx, request); err != nil {
+			return nil, SendReactionOutput{
+				Success: false,
+				Message: fmt.Sprintf("Failed to send reaction: %v", err),
+			}, nil
+		}
+
+		return nil, SendReactionOutput{
+			Success: true,
+			Message: "Reaction sent",
+		}, nil
+	}
+}
+
+type RemoveReactionInput struct {
+	Chat          string `json:"chat"`
+	MessageID     int    `json:"message_id"`
+	Emoji         string `json:"emoji,omitempty"`
+	CustomEmojiID int64  `json:"custom_emoji_id,omitempty"`
+}
+
+type RemoveReactionOutput struct {
+	Success bool   `json:"success"`
+	Message string `json:"message,omitempty"`
+}
+
+func RemoveReaction(c *client.Client) func(ctx context.Context, req *mcp.CallToolRequest, input RemoveReactionInput) (*mcp.CallToolResult, RemoveReactionOutput, error) {
+	return func(ctx context.Context, req *mcp.CallToolRequest, input RemoveReactionInput) (*mcp.CallToolResult, RemoveReactionOutput, error) {
+		if !c.IsAuthorized() {
+			return nil, RemoveReactionOutput{
+				Success: false,
+				Message: "Not authorized",
+			}, nil
+		}
+
+		api := c.API()
+		if api == nil {
+			return nil, RemoveReactionOutput{
+				Success: false,
+				Message: "Client is not running",
+			}, nil
+		}
+
+		reaction, err := inputReaction(input.Emoji, input.CustomEmojiID)
+		if err != nil {
+			return nil, RemoveReactionOutput{
+				Success: false,
+				Message: fmt.Sprintf("Invalid reaction: %v", err),
+			}, nil
+		}
+
+		peer, err := c.Resolve(ctx, input.Chat)
+		if err != nil {
+			return nil, RemoveReactionOutput{
+				Success: false,
+				Message: fmt.Sprintf("Failed to resolve chat: %v", err),
+			}, nil
+		}
+
+		// Without a reaction every reaction of ours is removed; otherwise
+		// the remaining ones are sent back.
+		var remaining []tg.ReactionClass
+		if reaction != nil {
+			chosen, err := chosenReactions(ctx, c, peer, input.MessageID)
+			if err != nil {
+				return nil, RemoveReactionOutput{
+					Success: false,
+					Message: fmt.Sprintf("Failed to get message: %v", err),
+				}, nil
+			}
+			if !containsReaction(chosen, reaction) {
+				return nil, RemoveReactionOutput{
+					Success: true,
+					Message: "Reaction was not set",
+				}, nil
+			}
+			for _, r := range chosen {
+				if !sameReaction(r, reaction) {
+					remaining = append(remaining, r)
+				}
+			}
+		}
+
+		request := &tg.MessagesSendReactionRequest{
+			Peer:  peer.InputPeer(),
+			MsgID: input.MessageID,
+		}
+		if len(remaining) > 0 {
+			request.SetReaction(remaining)
+		}
+
+		if _, err := api.MessagesSendReaction(ctx, request); err != nil {
+			return nil, RemoveReactionOutput{
+				Success: false,
+				Message: fmt.Sprintf("Failed to remove reaction: %v", err),
+			}, nil
+		}
+
+		return nil, RemoveReactionOutput{
+			Success: true,
+			Message: "Reaction removed",
+		}, nil
+	}
+}
+
+func containsReaction(reactions []tg.ReactionClass, r tg.ReactionClass) bool {
+	for _, other := range reactions {
+		if sameReaction(other, r) {
+			return true
+		}
+	}
+	return false
+}
+
+type GetReactionsInput struct {
+	Chat          string `json:"chat"`
+	MessageID     int    `json:"message_id"`
+	Emoji         string `json:"emoji,omitempty"`
+	CustomEmojiID int64  `json:"custom_emoji_id,omitempty"`
+	Limit         int    `json:"limit,omitempty"`
+	Cursor        string `json:"cursor,omitempty"`
+}
+
+type ReactionUser struct {
+	From ChatRef `json:"from"`
+	Reaction
+	Date string `json:"date"`
+	Big  bool   `json:"big,omitempty"`
+}
+
+type GetReactionsOutput struct {
+	Success    bool           `json:"success"`
+	Reactions  []ReactionUser `json:"reactions,omitempty"`
+	Total      int            `json:"total,omitempty"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+	Message    string         `json:"message,omitempty"`
+}
+
+func GetReactions(c *client.Client) func(ctx context.Context, req *mcp.CallToolRequest, input GetReactionsInput) (*mcp.CallToolResult, GetReactionsOutput, error) {
+	return func(ctx context.Context, req *mcp.CallToolRequest, input GetReactionsInput) (*mcp.CallToolResult, GetReactionsOutput, error) {
+		if !c.IsAuthorized() {
+			return nil, GetReactionsOutput{
+				Success: false,
+				Message: "Not authorized",
+			}, nil
+		}
+
+		api := c.API()
+		if api == nil {
+			return nil, GetReactionsOutput{
+				Success: false,
+				Message: "Client is not running",
+			}, nil
+		}
+
+		reaction, err := inputReaction(input.Emoji, input.CustomEmojiID)
+		if err != nil {
+			return nil, GetReactionsOutput{
+				Success: false,
+				Message: fmt.Sprintf("Invalid reaction: %v", err),
+			}, nil
+		}
+
+		limit := input.Limit
+		if limit <= 0 {
+			limit = 50
+		}
+		if limit > 100 {
+			limit = 100
+		}
+
+		inputPeer, err := c.ResolvePeer(ctx, input.Chat)
+		if err != nil {
+			return nil, GetReactionsOutput{
+				Success: false,
+				Message: fmt.Sprintf("Failed to resolve chat: %v", err),
+			}, nil
+		}
+
+		request := &tg.MessagesGetMessageReactionsListRequest{
+			Peer:  inputPeer,
+			ID:    input.MessageID,
+			Limit: limit,
+		}
+		if reaction != nil {
+			request.SetReaction(reaction)
+		}
+		if input.Cursor != "" {
+			request.SetOffset(input.Cursor)
+		}
+
+		resp, err := api.MessagesGetMessageReactionsList(ctx, request)
+		if err != nil {
+			return nil, GetReactionsOutput{
+				Success: false,
+				Message: fmt.Sprintf("Failed to get reactions: %v", err),
+			}, nil
+		}
+
+		refs := newPeerRefs(c, resp.Users, resp.Chats)
+		result := make([]ReactionUser, 0, len(resp.Reactions))
+		for _, r := range resp.Reactions {
+			result = append(result, ReactionUser{
+				From:     refs.ref(r.PeerID),
+				Reaction: newReaction(r.Reaction),
+				Date:     formatDate(r.Date),
+				Big:      r.Big,
+			})
+		}
+
+		return nil, GetReactionsOutput{
+			Success:    true,
+			Reactions:  result,
+			Total:      resp.Count,
+			NextCursor: resp.NextOffset,
+		}, nil
+	}
+}
+
+func RegisterReactionsTools(server *mcp.Server, c *client.Client) {
+	addTool(server, c, &mcp.Tool{
+		Name:        "send_reaction",
+		Description: "React to a message with a standard emoji (emoji) or a custom emoji (custom_emoji_id, needs Premium). Replaces your current reaction unless add=true; big=true plays the large animation.",
+	}, SendReaction(c))
+
+	addTool(server, c, &mcp.Tool{
+		Name:        "remove_reaction",
+		Description: "Remove your reaction from a message: the given emoji or custom_emoji_id, or all of them if neither is set.",
+	}, RemoveReaction(c))
+
+	addUserTool(server, c, &mcp.Tool{
+		Name:        "get_reactions",
+		Description: "List who reacted to a message, optionally only with a given emoji or custom_emoji_id. Only available in groups and for your own messages in private chats. Pass next_cursor as cursor for the next page.",
+	}, GetReactions(c))
+}
@@ -0,0 +1,86 @@
+package tools
+
+import (
+	"github.com/gotd/td/tg"
+
+	"tg-mcp/client"
+)
+
+type refKey struct {
+	kind string
+	id   int64
+}
+
+// peerRefs describes the users and chats referenced by messages, using the
+// users and chats that came with the response and falling back to the peer
+// cache for messages served from the archive.
+type peerRefs struct {
+	c    *client.Client
+	refs map[refKey]ChatRef
+}
+
+func newPeerRefs(c *client.Client, users []tg.UserClass, chats []tg.ChatClass) *peerRefs {
+	r := &peerRefs{c: c, refs: make(map[refKey]ChatRef, len(users)+len(chats))}
+	for _, u := range users {
+		if user, ok := u.(*tg.User); ok {
+			r.addUser(user)
+		}
+	}
+	for _, ch := range chats {
+		r.addChat(ch)
+	}
+	return r
+}
+
+func entityRefs(c *client.Client, e tg.Entities) *peerRefs {
+	r := &peerRefs{c: c, refs: make(map[refKey]ChatRef)}
+	for _, user := range e.Users {
+		r.addUser(user)
+	}
+	for _, chat := range e.Chats {
+		r.addChat(chat)
+	}
+	for _, channel := range e.Channels {
+		r.addChat(channel)
+	}
+	return r
+}
+
+func (r *peerRefs) addUser(u *tg.User) {
+	r.refs[refKey{client.PeerUser, u.ID}] = ChatRef{
+		ID:       u.ID,
+		Type:     client.PeerUser,
+		Title:    userName(u),
+		Username: u.Username,
+	}
+}
+
+func (r *peerRefs) addChat(ch tg.ChatClass) {
+	switch ch := ch.(type) {
+	case *tg.Chat:
+		r.refs[refKey{client.PeerChat, ch.ID}] = ChatRef{ID: ch.ID, Type: client.PeerChat, Title: ch.Title}
+	case *tg.ChatForbidden:
+		r.refs[refKey{client.PeerChat, ch.ID}] = ChatRef{ID: ch.ID, Type: client.PeerChat, Title: ch.Title}
+	case *tg.Channel:
+		r.refs[refKey{client.PeerChannel, ch.ID}] = ChatRef{
+			ID:       ch.ID,
+			Type:     client.PeerChannel,
+			Title:    ch.Title,
+			Username: ch.Username,
+		}
+	case *tg.ChannelForbidden:
+		r.refs[refKey{client.PeerChannel, ch.ID}] = ChatRef{ID: ch.ID, Type: client.PeerChannel, Title: ch.Title}
+	}
+}
+
+func (r *peerRefs) ref(p tg.PeerClass) ChatRef {
+	peer := r.c.PeerOf(p)
+	if ref, ok := r.refs[refKey{peer.Kind, peer.ID}]; ok {
+		return ref
+	}
+	return chatRef(peer)
+}
+
+func (r *peerRefs) user(id int64) ChatRef {
+	return r.ref(&tg.PeerUser{UserID: id})
+}
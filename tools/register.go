@@ -0,0 +1,25 @@
+package tools
+
+import (
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"tg-mcp/client"
+)
+
+// Every tool is registered through addTool or addUserTool, so each one
+// states whether it works when the server runs as a bot.
+
+// addTool registers a tool whose methods are open to both user accounts and
+// bots.
+func addTool[In, Out any](server *mcp.Server, c *client.Client, t *mcp.Tool, h mcp.ToolHandlerFor[In, Out]) {
+	mcp.AddTool(server, t, h)
+}
+
+// addUserTool registers a tool that needs a user account. Bots cannot call
+// the methods behind it, so it is left out when running as a bot.
+func addUserTool[In, Out any](server *mcp.Server, c *client.Client, t *mcp.Tool, h mcp.ToolHandlerFor[In, Out]) {
+	if c.IsBot() {
+		return
+	}
+	mcp.AddTool(server, t, h)
+}
@@ -0,0 +1,117 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"tg-mcp/client"
+)
+
+type GetRepliesInput struct {
+	Chat      string `json:"chat"`
+	MessageID int    `json:"message_id"`
+	Limit     int    `json:"limit,omitempty"`
+	OffsetID  int    `json:"offset_id,omitempty"`
+	Format    string `json:"format,omitempty"`
+}
+
+type GetRepliesOutput struct {
+	Success     bool      `json:"success"`
+	Discussion  *ChatRef  `json:"discussion,omitempty"`
+	Root        *Message  `json:"root,omitempty"`
+	Messages    []Message `json:"messages,omitempty"`
+	UnreadCount int       `json:"unread_count,omitempty"`
+	NextOffset  int       `json:"next_offset,omitempty"`
+	HasMore     bool      `json:"has_more"`
+	Total       int       `json:"total,omitempty"`
+	Message     string    `json:"message,omitempty"`
+}
+
+func GetReplies(c *client.Client) func(ctx context.Context, req *mcp.CallToolRequest, input GetRepliesInput) (*mcp.CallToolResult, GetRepliesOutput, error) {
+	return func(ctx context.Context, req *mcp.CallToolRequest, input GetRepliesInput) (*mcp.CallToolResult, GetRepliesOutput, error) {
+		if !c.IsAuthorized() {
+			return nil, GetRepliesOutput{
+				Success: false,
+				Message: "Not authorized",
+			}, nil
+		}
+
+		if !validTextFormat(input.Format) {
+			return nil, GetRepliesOutput{
+				Success: false,
+				Message: fmt.Sprintf("Unknown format: %s", input.Format),
+			}, nil
+		}
+
+		limit := input.Limit
+		if limit <= 0 {
+			limit = 50
+		}
+		if limit > 100 {
+			limit = 100
+		}
+
+		peer, err := c.Resolve(ctx, input.Chat)
+		if err != nil {
+			return nil, GetRepliesOutput{
+				Success: false,
+				Message: fmt.Sprintf("Failed to resolve chat: %v", err),
+			}, nil
+		}
+
+		discussion, err := c.Discussion(ctx, peer, input.MessageID)
+		if err != nil {
+			return nil, GetRepliesOutput{
+				Success: false,
+				Message: fmt.Sprintf("Failed to find discussion: %v", err),
+			}, nil
+		}
+
+		replies, err := c.Replies(ctx, discussion.Peer, discussion.MessageID, client.HistoryOptions{
+			OffsetID: input.OffsetID,
+			Limit:    limit,
+		})
+		if err != nil {
+			return nil, GetRepliesOutput{
+				Success: false,
+				Message: fmt.Sprintf("Failed to get replies: %v", err),
+			}, nil
+		}
+
+		refs := newPeerRefs(c, append(replies.Users, discussion.Users...), append(replies.Chats, discussion.Chats...))
+		output := GetRepliesOutput{
+			Success:     true,
+			UnreadCount: discussion.UnreadCount,
+			Total:       replies.Count,
+			HasMore:     len(replies.Messages) == limit,
+		}
+
+		chat := chatRef(discussion.Peer)
+		output.Discussion = &chat
+		if root, ok := convertMessage(discussion.Message, refs, input.Format); ok {
+			output.Root = &root
+		}
+
+		var lastID int
+		for _, msg := range replies.Messages {
+			if m, ok := convertMessage(msg, refs, input.Format); ok {
+				output.Messages = append(output.Messages, m)
+				lastID = m.ID
+			}
+		}
+		if output.HasMore && lastID > 0 {
+			output.NextOffset = lastID
+		}
+
+		return nil, output, nil
+	}
+}
+
+func RegisterRepliesTools(server *mcp.Server, c *client.Client) {
+	addUserTool(server, c, &mcp.Tool{
+		Name:        "get_replies",
+		Description: "Get the replies to a message, newest first: the comments under a channel post (read from its linked discussion group) or a reply thread in a group. Returns the discussion chat and its root message; use limit (up to 100) and pass next_offset as offset_id for older replies. Supports the same format values as get_messages.",
+	}, GetReplies(c))
+}
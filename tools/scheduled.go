@@ -0,0 +1,281 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gotd/td/telegram/message"
+	"github.com/gotd/td/tg"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"tg-mcp/client"
+)
+
+// parseScheduleDate accepts an RFC3339 time or a relative one such as
+// "in 2h", "in 1h30m" or "in 3d". An empty string means send now.
+func parseScheduleDate(s string, now time.Time) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, nil
+	}
+
+	var when time.Time
+	if rest, ok := strings.CutPrefix(s, "in "); ok {
+		d, err := parseRelative(strings.ReplaceAll(rest, " ", ""))
+		if err != nil {
+			return time.Time{}, err
+		}
+		when = now.Add(d)
+	} else {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return time.Time{}, fmt.Errorf(`expected RFC3339 or "in <duration>", got %q`, s)
+		}
+		when = t
+	}
+
+	if !when.After(now) {
+		return time.Time{}, fmt.Errorf("%s is not in the future", when.Format(time.RFC3339))
+	}
+	return when, nil
+}
+
+// parseRelative parses a Go duration with an optional leading number of
+// days, such as 2d or 1d12h.
+func parseRelative(s string) (time.Duration, error) {
+	var days time.Duration
+	if i := strings.IndexByte(s, 'd'); i >= 0 {
+		n, err := strconv.Atoi(s[:i])
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		days = time.Duration(n) * 24 * time.Hour
+		s = s[i+1:]
+		if s == "" {
+			return days, nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	return days + d, nil
+}
+
+// schedule makes builder schedule its message for when, unless when is
+// zero.
+func schedule(builder *message.Builder, when time.Time) *message.Builder {
+	if when.IsZero() {
+		return builder
+	}
+	return builder.Schedule(when)
+}
+
+// sentMessage words the result of sending a message that may have been
+// scheduled.
+func sentMessage(sent string, when time.Time) string {
+	if when.IsZero() {
+		return sent
+	}
+	return fmt.Sprintf("Scheduled for %s", when.Format(time.RFC3339))
+}
+
+type ListScheduledInput struct {
+	Chat   string `json:"chat"`
+	Format string `json:"format,omitempty"`
+}
+
+type ListScheduledOutput struct {
+	Success  bool      `json:"success"`
+	Messages []Message `json:"messages,omitempty"`
+	Message  string    `json:"message,omitempty"`
+}
+
+func ListScheduled(c *client.Client) func(ctx context.Context, req *mcp.CallToolRequest, input ListScheduledInput) (*mcp.CallToolResult, ListScheduledOutput, error) {
+	return func(ctx context.Context, req *mcp.CallToolRequest, input ListScheduledInput) (*mcp.CallToolResult, ListScheduledOutput, error) {
+		if !c.IsAuthorized() {
+			return nil, ListScheduledOutput{
+				Success: false,
+				Message: "Not authorized",
+			}, nil
+		}
+
+		api := c.API()
+		if api == nil {
+			return nil, ListScheduledOutput{
+				Success: false,
+				Message: "Client is not running",
+			}, nil
+		}
+
+		if !validTextFormat(input.Format) {
+			return nil, ListScheduledOutput{
+				Success: false,
+				Message: fmt.Sprintf("Unknown format: %s", input.Format),
+			}, nil
+		}
+
+		inputPeer, err := c.ResolvePeer(ctx, input.Chat)
+		if err != nil {
+			return nil, ListScheduledOutput{
+				Success: false,
+				Message: fmt.Sprintf("Failed to resolve chat: %v", err),
+			}, nil
+		}
+
+		resp, err := api.MessagesGetScheduledHistory(ctx, &tg.MessagesGetScheduledHistoryRequest{
+			Peer: inputPeer,
+		})
+		if err != nil {
+			return nil, ListScheduledOutput{
+				Success: false,
+				Message: fmt.Sprintf("Failed to get scheduled messages: %v", err),
+			}, nil
+		}
+
+		messages, users, chats := extractMessages(resp)
+		refs := newPeerRefs(c, users, chats)
+
+		result := make([]Message, 0, len(messages))
+		for _, msg := range messages {
+			if m, ok := convertMessage(msg, refs, input.Format); ok {
+				result = append(result, m)
+			}
+		}
+
+		return nil, ListScheduledOutput{
+			Success:  true,
+			Messages: result,
+		}, nil
+	}
+}
+
+type ScheduledMessagesInput struct {
+	Chat       string `json:"chat"`
+	MessageIDs []int  `json:"message_ids"`
+}
+
+type ScheduledMessagesOutput struct {
+	Success bool   `json:"success"`
+	Message string `json:"message,omitempty"`
+}
+
+func SendScheduledNow(c *client.Client) func(ctx context.Context, req *mcp.CallToolRequest, input ScheduledMessagesInput) (*mcp.CallToolResult, ScheduledMessagesOutput, error) {
+	return func(ctx context.Context, req *mcp.CallToolRequest, input ScheduledMessagesInput) (*mcp.CallToolResult, ScheduledMessagesOutput, error) {
+		if !c.IsAuthorized() {
+			return nil, ScheduledMessagesOutput{
+				Success: false,
+				Message: "Not authorized",
+			}, nil
+		}
+
+		api := c.API()
+		if api == nil {
+			return nil, ScheduledMessagesOutput{
+				Success: false,
+				Message: "Client is not running",
+			}, nil
+		}
+
+		if len(input.MessageIDs) == 0 {
+			return nil, ScheduledMessagesOutput{
+				Success: false,
+				Message: "Provide at least one message ID",
+			}, nil
+		}
+
+		inputPeer, err := c.ResolvePeer(ctx, input.Chat)
+		if err != nil {
+			return nil, ScheduledMessagesOutput{
+				Success: false,
+				Message: fmt.Sprintf("Failed to resolve chat: %v", err),
+			}, nil
+		}
+
+		_, err = api.MessagesSendScheduledMessages(ctx, &tg.MessagesSendScheduledMessagesRequest{
+			Peer: inputPeer,
+			ID:   input.MessageIDs,
+		})
+		if err != nil {
+			return nil, ScheduledMessagesOutput{
+				Success: false,
+				Message: fmt.Sprintf("Failed to send scheduled messages: %v", err),
+			}, nil
+		}
+
+		return nil, ScheduledMessagesOutput{
+			Success: true,
+			Message: fmt.Sprintf("Sent %d scheduled messages", len(input.MessageIDs)),
+		}, nil
+	}
+}
+
+func DeleteScheduled(c *client.Client) func(ctx context.Context, req *mcp.CallToolRequest, input ScheduledMessagesInput) (*mcp.CallToolResult, ScheduledMessagesOutput, error) {
+	return func(ctx context.Context, req *mcp.CallToolRequest, input ScheduledMessagesInput) (*mcp.CallToolResult, ScheduledMessagesOutput, error) {
+		if !c.IsAuthorized() {
+			return nil, ScheduledMessagesOutput{
+				Success: false,
+				Message: "Not authorized",
+			}, nil
+		}
+
+		api := c.API()
+		if api == nil {
+			return nil, ScheduledMessagesOutput{
+				Success: false,
+				Message: "Client is not running",
+			}, nil
+		}
+
+		if len(input.MessageIDs) == 0 {
+			return nil, ScheduledMessagesOutput{
+				Success: false,
+				Message: "Provide at least one message ID",
+			}, nil
+		}
+
+		inputPeer, err := c.ResolvePeer(ctx, input.Chat)
+		if err != nil {
+			return nil, ScheduledMessagesOutput{
+				Success: false,
+				Message: fmt.Sprintf("Failed to resolve chat: %v", err),
+			}, nil
+		}
+
+		_, err = api.MessagesDeleteScheduledMessages(ctx, &tg.MessagesDeleteScheduledMessagesRequest{
+			Peer: inputPeer,
+			ID:   input.MessageIDs,
+		})
+		if err != nil {
+			return nil, ScheduledMessagesOutput{
+				Success: false,
+				Message: fmt.Sprintf("Failed to delete scheduled messages: %v", err),
+			}, nil
+		}
+
+		return nil, ScheduledMessagesOutput{
+			Success: true,
+			Message: fmt.Sprintf("Deleted %d scheduled messages", len(input.MessageIDs)),
+		}, nil
+	}
+}
+
+func RegisterScheduledTools(server *mcp.Server, c *client.Client) {
+	addUserTool(server, c, &mcp.Tool{
+		Name:        "list_scheduled",
+		Description: "List the messages scheduled in a chat; each message's date is when it will be sent. Supports the same format values as get_messages.",
+	}, ListScheduled(c))
+
+	addUserTool(server, c, &mcp.Tool{
+		Name:        "send_scheduled_now",
+		Description: "Send scheduled messages immediately, by the IDs returned by list_scheduled.",
+	}, SendScheduledNow(c))
+
+	addUserTool(server, c, &mcp.Tool{
+		Name:        "delete_scheduled",
+		Description: "Delete scheduled messages before they are sent, by the IDs returned by list_scheduled.",
+	}, DeleteScheduled(c))
+}
@@ -0,0 +1,310 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gotd/td/tg"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"tg-mcp/client"
+)
+
+type SearchMessagesInput struct {
+	Query   string `json:"query,omitempty"`
+	Chat    string `json:"chat,omitempty"`
+	TopicID int    `json:"topic_id,omitempty"`
+	From    string `json:"from,omitempty"`
+	MinDate string `json:"min_date,omitempty"`
+	MaxDate string `json:"max_date,omitempty"`
+	Filter  string `json:"filter,omitempty"`
+	Limit   int    `json:"limit,omitempty"`
+	Cursor  string `json:"cursor,omitempty"`
+	Format  string `json:"format,omitempty"`
+}
+
+type SearchMessagesOutput struct {
+	Success    bool          `json:"success"`
+	Messages   []ChatMessage `json:"messages,omitempty"`
+	Total      int           `json:"total,omitempty"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+	HasMore    bool          `json:"has_more"`
+	Message    string        `json:"message,omitempty"`
+}
+
+func SearchMessages(c *client.Client) func(ctx context.Context, req *mcp.CallToolRequest, input SearchMessagesInput) (*mcp.CallToolResult, SearchMessagesOutput, error) {
+	return func(ctx context.Context, req *mcp.CallToolRequest, input SearchMessagesInput) (*mcp.CallToolResult, SearchMessagesOutput, error) {
+		if !c.IsAuthorized() {
+			return nil, SearchMessagesOutput{
+				Success: false,
+				Message: "Not authorized",
+			}, nil
+		}
+
+		api := c.API()
+		if api == nil {
+			return nil, SearchMessagesOutput{
+				Success: false,
+				Message: "Client is not running",
+			}, nil
+		}
+
+		if !validTextFormat(input.Format) {
+			return nil, SearchMessagesOutput{
+				Success: false,
+				Message: fmt.Sprintf("Unknown format: %s", input.Format),
+			}, nil
+		}
+
+		limit := input.Limit
+		if limit <= 0 {
+			limit = 20
+		}
+		if limit > 100 {
+			limit = 100
+		}
+
+		filter, ok := parseSearchFilter(input.Filter)
+		if !ok {
+			return nil, SearchMessagesOutput{
+				Success: false,
+				Message: fmt.Sprintf("Unknown filter: %s", input.Filter),
+			}, nil
+		}
+
+		minDate, err := parseDate(input.MinDate, false)
+		if err != nil {
+			return nil, SearchMessagesOutput{
+				Success: false,
+				Message: fmt.Sprintf("Invalid min_date: %v", err),
+			}, nil
+		}
+		maxDate, err := parseDate(input.MaxDate, true)
+		if err != nil {
+			return nil, SearchMessagesOutput{
+				Success: false,
+				Message: fmt.Sprintf("Invalid max_date: %v", err),
+			}, nil
+		}
+
+		var resp tg.MessagesMessagesClass
+		if input.Chat != "" {
+			inputPeer, err := c.ResolvePeer(ctx, input.Chat)
+			if err != nil {
+				return nil, SearchMessagesOutput{
+					Success: false,
+					Message: fmt.Sprintf("Failed to resolve chat: %v", err),
+				}, nil
+			}
+
+			request := &tg.MessagesSearchRequest{
+				Peer:     inputPeer,
+				Q:        input.Query,
+				Filter:   filter,
+				MinDate:  minDate,
+				MaxDate:  maxDate,
+				TopMsgID: input.TopicID,
+				Limit:    limit,
+			}
+
+			if input.From != "" {
+				fromPeer, err := c.ResolvePeer(ctx, input.From)
+				if err != nil {
+					return nil, SearchMessagesOutput{
+						Success: false,
+						Message: fmt.Sprintf("Failed to resolve sender: %v", err),
+					}, nil
+				}
+				request.FromID = fromPeer
+			}
+
+			if input.Cursor != "" {
+				request.OffsetID, err = strconv.Atoi(input.Cursor)
+				if err != nil {
+					return nil, SearchMessagesOutput{
+						Success: false,
+						Message: "Invalid cursor",
+					}, nil
+				}
+			}
+
+			resp, err = api.MessagesSearch(ctx, request)
+			if err != nil {
+				return nil, SearchMessagesOutput{
+					Success: false,
+					Message: fmt.Sprintf("Failed to search messages: %v", err),
+				}, nil
+			}
+		} else {
+			if input.From != "" {
+				return nil, SearchMessagesOutput{
+					Success: false,
+					Message: "Filtering by sender requires a chat",
+				}, nil
+			}
+			if input.TopicID != 0 {
+				return nil, SearchMessagesOutput{
+					Success: false,
+					Message: "Filtering by topic requires a chat",
+				}, nil
+			}
+
+			request := &tg.MessagesSearchGlobalRequest{
+				Q:          input.Query,
+				Filter:     filter,
+				MinDate:    minDate,
+				MaxDate:    maxDate,
+				OffsetPeer: &tg.InputPeerEmpty{},
+				Limit:      limit,
+			}
+
+			if input.Cursor != "" {
+				if err := parseGlobalCursor(c, input.Cursor, request); err != nil {
+					return nil, SearchMessagesOutput{
+						Success: false,
+						Message: fmt.Sprintf("Invalid cursor: %v", err),
+					}, nil
+				}
+			}
+
+			resp, err = api.MessagesSearchGlobal(ctx, request)
+			if err != nil {
+				return nil, SearchMessagesOutput{
+					Success: false,
+					Message: fmt.Sprintf("Failed to search messages: %v", err),
+				}, nil
+			}
+		}
+
+		messages, users, chats := extractMessages(resp)
+		refs := newPeerRefs(c, users, chats)
+
+		result := make([]ChatMessage, 0, len(messages))
+		var last *tg.Message
+		for _, msg := range messages {
+			m, ok := msg.(*tg.Message)
+			if !ok {
+				continue
+			}
+			result = append(result, ChatMessage{
+				Chat:    refs.ref(m.PeerID),
+				Message: newMessage(m, refs, input.Format),
+			})
+			last = m
+		}
+
+		output := SearchMessagesOutput{
+			Success:  true,
+			Messages: result,
+			Total:    len(messages),
+			HasMore:  len(messages) == limit && last != nil,
+		}
+
+		var nextRate int
+		switch r := resp.(type) {
+		case *tg.MessagesMessagesSlice:
+			output.Total = r.Count
+			nextRate = r.NextRate
+		case *tg.MessagesChannelMessages:
+			output.Total = r.Count
+		}
+
+		if output.HasMore {
+			if input.Chat != "" {
+				output.NextCursor = strconv.Itoa(last.ID)
+			} else {
+				if nextRate == 0 {
+					nextRate = last.Date
+				}
+				chat := refs.ref(last.PeerID)
+				output.NextCursor = fmt.Sprintf("%d:%s:%d:%d", nextRate, chat.Type, chat.ID, last.ID)
+			}
+		}
+
+		return nil, output, nil
+	}
+}
+
+func parseGlobalCursor(c *client.Client, cursor string, request *tg.MessagesSearchGlobalRequest) error {
+	parts := strings.Split(cursor, ":")
+	if len(parts) != 4 {
+		return fmt.Errorf("expected 4 fields, got %d", len(parts))
+	}
+
+	rate, err1 := strconv.Atoi(parts[0])
+	peerID, err2 := strconv.ParseInt(parts[2], 10, 64)
+	offsetID, err3 := strconv.Atoi(parts[3])
+	if err1 != nil || err2 != nil || err3 != nil {
+		return fmt.Errorf("malformed number")
+	}
+
+	peer, ok := c.Peers().Get(parts[1], peerID)
+	if !ok {
+		return fmt.Errorf("unknown chat %s %d", parts[1], peerID)
+	}
+
+	request.OffsetRate = rate
+	request.OffsetPeer = peer.InputPeer()
+	request.OffsetID = offsetID
+	return nil
+}
+
+func parseSearchFilter(filter string) (tg.MessagesFilterClass, bool) {
+	switch filter {
+	case "":
+		return &tg.InputMessagesFilterEmpty{}, true
+	case "photos":
+		return &tg.InputMessagesFilterPhotos{}, true
+	case "videos":
+		return &tg.InputMessagesFilterVideo{}, true
+	case "photo_video":
+		return &tg.InputMessagesFilterPhotoVideo{}, true
+	case "documents":
+		return &tg.InputMessagesFilterDocument{}, true
+	case "links":
+		return &tg.InputMessagesFilterURL{}, true
+	case "voice":
+		return &tg.InputMessagesFilterVoice{}, true
+	case "round_video":
+		return &tg.InputMessagesFilterRoundVideo{}, true
+	case "music":
+		return &tg.InputMessagesFilterMusic{}, true
+	case "gifs":
+		return &tg.InputMessagesFilterGif{}, true
+	case "pinned":
+		return &tg.InputMessagesFilterPinned{}, true
+	default:
+		return nil, false
+	}
+}
+
+// parseDate accepts RFC3339 timestamps or plain YYYY-MM-DD dates and
+// returns a Unix timestamp. An empty string yields zero. Telegram excludes
+// messages sent at max_date itself, so with endOfDay a plain date yields
+// the start of the next day to keep the whole day in range.
+func parseDate(s string, endOfDay bool) (int, error) {
+	if s == "" {
+		return 0, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return int(t.Unix()), nil
+	}
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		return 0, fmt.Errorf("expected RFC3339 or YYYY-MM-DD, got %q", s)
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return int(t.Unix()), nil
+}
+
+func RegisterSearchTools(server *mcp.Server, c *client.Client) {
+	addUserTool(server, c, &mcp.Tool{
+		Name:        "search_messages",
+		Description: "Search messages in one chat (chat) or across all chats (no chat). Filters: from (sender, requires chat), topic_id (forum topic, requires chat), min_date/max_date (RFC3339 or YYYY-MM-DD; a max_date given as a date includes that whole day), filter (photos, videos, photo_video, documents, links, voice, round_video, music, gifs, pinned). Pass next_cursor as cursor to get the next page. Supports the same format values as get_messages.",
+	}, SearchMessages(c))
+}
@@ -3,28 +3,38 @@ package tools
 import (
 	"context"
 	"fmt"
+	"time"
 
+	"github.com/gotd/td/telegram/message"
 	"github.com/gotd/td/tg"
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 
 	"tg-mcp/client"
+	"tg-mcp/format"
 )
 
 type SendMessageInput struct {
-	Chat string `json:"chat"`
-	Text string `json:"text"`
+	Chat         string `json:"chat"`
+	TopicID      int    `json:"topic_id,omitempty"`
+	Text         string `json:"text"`
+	ParseMode    string `json:"parse_mode,omitempty"`
+	ScheduleDate string `json:"schedule_date,omitempty"`
 }
 
 type ReplyMessageInput struct {
-	Chat      string `json:"chat"`
-	Text      string `json:"text"`
-	MessageID int    `json:"message_id"`
+	Chat         string `json:"chat"`
+	Text         string `json:"text"`
+	MessageID    int    `json:"message_id"`
+	ParseMode    string `json:"parse_mode,omitempty"`
+	Comment      bool   `json:"comment,omitempty"`
+	ScheduleDate string `json:"schedule_date,omitempty"`
 }
 
 type ReplyMessageOutput struct {
-	Success   bool   `json:"success"`
-	MessageID int    `json:"message_id,omitempty"`
-	Message   string `json:"message,omitempty"`
+	Success   bool     `json:"success"`
+	MessageID int      `json:"message_id,omitempty"`
+	Chat      *ChatRef `json:"chat,omitempty"`
+	Message   string   `json:"message,omitempty"`
 }
 
 type SendMessageOutput struct {
@@ -50,8 +60,22 @@ func SendMessage(c *client.Client) func(ctx context.Context, req *mcp.CallToolRe
 			}, nil
 		}
 
-		api := c.API()
-		inputPeer, err := getPeerFromDialogsOrResolve(ctx, api, input.Chat)
+		if input.ScheduleDate != "" && c.IsBot() {
+			return nil, SendMessageOutput{
+				Success: false,
+				Message: "Bots cannot schedule messages",
+			}, nil
+		}
+
+		when, err := parseScheduleDate(input.ScheduleDate, time.Now())
+		if err != nil {
+			return nil, SendMessageOutput{
+				Success: false,
+				Message: fmt.Sprintf("Invalid schedule_date: %v", err),
+			}, nil
+		}
+
+		inputPeer, err := c.ResolvePeer(ctx, input.Chat)
 		if err != nil {
 			return nil, SendMessageOutput{
 				Success: false,
@@ -59,7 +83,20 @@ func SendMessage(c *client.Client) func(ctx context.Context, req *mcp.CallToolRe
 			}, nil
 		}
 
-		updates, err := sender.To(inputPeer).Text(ctx, input.Text)
+		text, err := styledText(c, input.Text, input.ParseMode)
+		if err != nil {
+			return nil, SendMessageOutput{
+				Success: false,
+				Message: fmt.Sprintf("Invalid formatting: %v", err),
+			}, nil
+		}
+
+		builder := &sender.To(inputPeer).Builder
+		if id := threadReply(0, input.TopicID); id != 0 {
+			builder = builder.Reply(id)
+		}
+
+		updates, err := schedule(builder, when).StyledText(ctx, text)
 		if err != nil {
 			return nil, SendMessageOutput{
 				Success: false,
@@ -72,7 +109,7 @@ func SendMessage(c *client.Client) func(ctx context.Context, req *mcp.CallToolRe
 		return nil, SendMessageOutput{
 			Success:   true,
 			MessageID: messageID,
-			Message:   "Message sent successfully",
+			Message:   sentMessage("Message sent successfully", when),
 		}, nil
 	}
 }
@@ -94,8 +131,22 @@ func ReplyMessage(c *client.Client) func(ctx context.Context, req *mcp.CallToolR
 			}, nil
 		}
 
-		api := c.API()
-		inputPeer, err := getPeerFromDialogsOrResolve(ctx, api, input.Chat)
+		if input.ScheduleDate != "" && c.IsBot() {
+			return nil, ReplyMessageOutput{
+				Success: false,
+				Message: "Bots cannot schedule messages",
+			}, nil
+		}
+
+		when, err := parseScheduleDate(input.ScheduleDate, time.Now())
+		if err != nil {
+			return nil, ReplyMessageOutput{
+				Success: false,
+				Message: fmt.Sprintf("Invalid schedule_date: %v", err),
+			}, nil
+		}
+
+		peer, err := c.Resolve(ctx, input.Chat)
 		if err != nil {
 			return nil, ReplyMessageOutput{
 				Success: false,
@@ -103,7 +154,33 @@ func ReplyMessage(c *client.Client) func(ctx context.Context, req *mcp.CallToolR
 			}, nil
 		}
 
-		updates, err := sender.To(inputPeer).Reply(input.MessageID).Text(ctx, input.Text)
+		// Comments on a channel post are replies to its copy in the
+		// linked discussion group.
+		replyTo := input.MessageID
+		var thread *ChatRef
+		if input.Comment {
+			discussion, err := c.Discussion(ctx, peer, input.MessageID)
+			if err != nil {
+				return nil, ReplyMessageOutput{
+					Success: false,
+					Message: fmt.Sprintf("Failed to find discussion: %v", err),
+				}, nil
+			}
+			peer, replyTo = discussion.Peer, discussion.MessageID
+			ref := chatRef(peer)
+			thread = &ref
+		}
+
+		text, err := styledText(c, input.Text, input.ParseMode)
+		if err != nil {
+			return nil, ReplyMessageOutput{
+				Success: false,
+				Message: fmt.Sprintf("Invalid formatting: %v", err),
+			}, nil
+		}
+
+		builder := sender.To(peer.InputPeer()).Reply(replyTo)
+		updates, err := schedule(builder, when).StyledText(ctx, text)
 		if err != nil {
 			return nil, ReplyMessageOutput{
 				Success: false,
@@ -116,11 +193,24 @@ func ReplyMessage(c *client.Client) func(ctx context.Context, req *mcp.CallToolR
 		return nil, ReplyMessageOutput{
 			Success:   true,
 			MessageID: messageID,
-			Message:   "Reply sent successfully",
+			Chat:      thread,
+			Message:   sentMessage("Reply sent successfully", when),
 		}, nil
 	}
 }
 
+// styledText parses text in the given parse mode, resolving user mentions
+// against the peer cache.
+func styledText(c *client.Client, text, parseMode string) (message.StyledTextOption, error) {
+	return format.Parse(text, parseMode, func(id int64) (tg.InputUserClass, error) {
+		peer, ok := c.Peers().Get(client.PeerUser, id)
+		if !ok {
+			return nil, fmt.Errorf("unknown user %d", id)
+		}
+		return &tg.InputUser{UserID: peer.ID, AccessHash: peer.AccessHash}, nil
+	})
+}
+
 func extractMessageID(updates tg.UpdatesClass) int {
 	switch u := updates.(type) {
 	case *tg.Updates:
@@ -135,6 +225,8 @@ func extractMessageID(updates tg.UpdatesClass) int {
 	return 0
 }
 
+const maxForwardMessages = 100
+
 type ForwardMessageInput struct {
 	FromChat  string `json:"from_chat"`
 	ToChat    string `json:"to_chat"`
@@ -164,7 +256,7 @@ func ForwardMessage(c *client.Client) func(ctx context.Context, req *mcp.CallToo
 			}, nil
 		}
 
-		fromPeer, err := getPeerFromDialogsOrResolve(ctx, api, input.FromChat)
+		fromPeer, err := c.ResolvePeer(ctx, input.FromChat)
 		if err != nil {
 			return nil, ForwardMessageOutput{
 				Success: false,
@@ -172,7 +264,7 @@ func ForwardMessage(c *client.Client) func(ctx context.Context, req *mcp.CallToo
 			}, nil
 		}
 
-		toPeer, err := getPeerFromDialogsOrResolve(ctx, api, input.ToChat)
+		toPeer, err := c.ResolvePeer(ctx, input.ToChat)
 		if err != nil {
 			return nil, ForwardMessageOutput{
 				Success: false,
@@ -180,11 +272,10 @@ func ForwardMessage(c *client.Client) func(ctx context.Context, req *mcp.CallToo
 			}, nil
 		}
 
-		updates, err := api.MessagesForwardMessages(ctx, &tg.MessagesForwardMessagesRequest{
+		forwarded, err := forwardMessages(ctx, api, &tg.MessagesForwardMessagesRequest{
 			FromPeer: fromPeer,
 			ToPeer:   toPeer,
 			ID:       []int{input.MessageID},
-			RandomID: []int64{int64(input.MessageID) + 1000000},
 		})
 		if err != nil {
 			return nil, ForwardMessageOutput{
@@ -193,103 +284,159 @@ func ForwardMessage(c *client.Client) func(ctx context.Context, req *mcp.CallToo
 			}, nil
 		}
 
-		messageID := extractMessageID(updates)
-
 		return nil, ForwardMessageOutput{
 			Success:   true,
-			MessageID: messageID,
+			MessageID: forwarded[input.MessageID],
 			Message:   "Message forwarded successfully",
 		}, nil
 	}
 }
 
-func getPeerFromDialogsOrResolve(ctx context.Context, api *tg.Client, chat string) (tg.InputPeerClass, error) {
-	chatID, isNumeric := parseID(chat)
+type ForwardMessagesInput struct {
+	FromChat          string `json:"from_chat"`
+	ToChat            string `json:"to_chat"`
+	MessageIDs        []int  `json:"message_ids"`
+	TopicID           int    `json:"topic_id,omitempty"`
+	DropAuthor        bool   `json:"drop_author,omitempty"`
+	DropMediaCaptions bool   `json:"drop_media_captions,omitempty"`
+	Silent            bool   `json:"silent,omitempty"`
+}
 
-	dialogs, err := api.MessagesGetDialogs(ctx, &tg.MessagesGetDialogsRequest{
-		OffsetPeer: &tg.InputPeerEmpty{},
-		Limit:      200,
-	})
-	if err != nil {
-		return nil, err
-	}
+type ForwardedMessage struct {
+	SourceID  int `json:"source_id"`
+	MessageID int `json:"message_id,omitempty"`
+}
 
-	var users []tg.UserClass
-	var chats []tg.ChatClass
+type ForwardMessagesOutput struct {
+	Success   bool               `json:"success"`
+	Forwarded []ForwardedMessage `json:"forwarded,omitempty"`
+	Message   string             `json:"message,omitempty"`
+}
 
-	switch d := dialogs.(type) {
-	case *tg.MessagesDialogs:
-		users = d.Users
-		chats = d.Chats
-	case *tg.MessagesDialogsSlice:
-		users = d.Users
-		chats = d.Chats
-	}
+func ForwardMessages(c *client.Client) func(ctx context.Context, req *mcp.CallToolRequest, input ForwardMessagesInput) (*mcp.CallToolResult, ForwardMessagesOutput, error) {
+	return func(ctx context.Context, req *mcp.CallToolRequest, input ForwardMessagesInput) (*mcp.CallToolResult, ForwardMessagesOutput, error) {
+		if !c.IsAuthorized() {
+			return nil, ForwardMessagesOutput{
+				Success: false,
+				Message: "Not authorized",
+			}, nil
+		}
 
-	for _, u := range users {
-		if user, ok := u.(*tg.User); ok {
-			if isNumeric && user.ID == chatID {
-				return &tg.InputPeerUser{UserID: user.ID, AccessHash: user.AccessHash}, nil
-			}
-			if !isNumeric && user.Username == chat {
-				return &tg.InputPeerUser{UserID: user.ID, AccessHash: user.AccessHash}, nil
-			}
+		api := c.API()
+		if api == nil {
+			return nil, ForwardMessagesOutput{
+				Success: false,
+				Message: "Client is not running",
+			}, nil
+		}
+
+		if len(input.MessageIDs) == 0 || len(input.MessageIDs) > maxForwardMessages {
+			return nil, ForwardMessagesOutput{
+				Success: false,
+				Message: fmt.Sprintf("Provide between 1 and %d message IDs", maxForwardMessages),
+			}, nil
+		}
+
+		fromPeer, err := c.ResolvePeer(ctx, input.FromChat)
+		if err != nil {
+			return nil, ForwardMessagesOutput{
+				Success: false,
+				Message: fmt.Sprintf("Failed to resolve source chat: %v", err),
+			}, nil
+		}
+
+		toPeer, err := c.ResolvePeer(ctx, input.ToChat)
+		if err != nil {
+			return nil, ForwardMessagesOutput{
+				Success: false,
+				Message: fmt.Sprintf("Failed to resolve destination chat: %v", err),
+			}, nil
 		}
+
+		request := &tg.MessagesForwardMessagesRequest{
+			FromPeer:          fromPeer,
+			ToPeer:            toPeer,
+			ID:                input.MessageIDs,
+			DropAuthor:        input.DropAuthor,
+			DropMediaCaptions: input.DropMediaCaptions,
+			Silent:            input.Silent,
+		}
+		if input.TopicID > generalTopicID {
+			request.TopMsgID = input.TopicID
+		}
+
+		forwarded, err := forwardMessages(ctx, api, request)
+		if err != nil {
+			return nil, ForwardMessagesOutput{
+				Success: false,
+				Message: fmt.Sprintf("Failed to forward messages: %v", err),
+			}, nil
+		}
+
+		result := make([]ForwardedMessage, 0, len(input.MessageIDs))
+		for _, id := range input.MessageIDs {
+			result = append(result, ForwardedMessage{SourceID: id, MessageID: forwarded[id]})
+		}
+
+		return nil, ForwardMessagesOutput{
+			Success:   true,
+			Forwarded: result,
+			Message:   fmt.Sprintf("Forwarded %d of %d messages", len(forwarded), len(input.MessageIDs)),
+		}, nil
 	}
+}
 
-	for _, ch := range chats {
-		switch c := ch.(type) {
-		case *tg.Chat:
-			if isNumeric && c.ID == chatID {
-				return &tg.InputPeerChat{ChatID: c.ID}, nil
-			}
-		case *tg.Channel:
-			if isNumeric && c.ID == chatID {
-				return &tg.InputPeerChannel{ChannelID: c.ID, AccessHash: c.AccessHash}, nil
-			}
-			if !isNumeric && c.Username == chat {
-				return &tg.InputPeerChannel{ChannelID: c.ID, AccessHash: c.AccessHash}, nil
-			}
+// forwardMessages sends request with fresh random IDs and returns the ID
+// of each forwarded copy by source message ID. Messages that could not be
+// forwarded, such as deleted ones, are missing from the result.
+func forwardMessages(ctx context.Context, api *tg.Client, request *tg.MessagesForwardMessagesRequest) (map[int]int, error) {
+	sources := make(map[int64]int, len(request.ID))
+	request.RandomID = make([]int64, 0, len(request.ID))
+	for _, id := range request.ID {
+		randomID, err := client.RandomID()
+		if err != nil {
+			return nil, err
 		}
+		request.RandomID = append(request.RandomID, randomID)
+		sources[randomID] = id
 	}
 
-	if !isNumeric {
-		resolved, err := api.ContactsResolveUsername(ctx, &tg.ContactsResolveUsernameRequest{
-			Username: chat,
-		})
-		if err == nil {
-			for _, u := range resolved.Users {
-				if user, ok := u.(*tg.User); ok {
-					return &tg.InputPeerUser{UserID: user.ID, AccessHash: user.AccessHash}, nil
-				}
-			}
-			for _, ch := range resolved.Chats {
-				switch c := ch.(type) {
-				case *tg.Chat:
-					return &tg.InputPeerChat{ChatID: c.ID}, nil
-				case *tg.Channel:
-					return &tg.InputPeerChannel{ChannelID: c.ID, AccessHash: c.AccessHash}, nil
+	updates, err := api.MessagesForwardMessages(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	forwarded := make(map[int]int, len(request.ID))
+	if u, ok := updates.(*tg.Updates); ok {
+		for _, update := range u.Updates {
+			if msg, ok := update.(*tg.UpdateMessageID); ok {
+				if source, ok := sources[msg.RandomID]; ok {
+					forwarded[source] = msg.ID
 				}
 			}
 		}
 	}
-
-	return nil, fmt.Errorf("chat not found: %s", chat)
+	return forwarded, nil
 }
 
 func RegisterSendTools(server *mcp.Server, c *client.Client) {
-	mcp.AddTool(server, &mcp.Tool{
+	addTool(server, c, &mcp.Tool{
 		Name:        "send_message",
-		Description: "Send a text message to a Telegram chat by username or ID",
+		Description: "Send a text message to a Telegram chat by username or ID. parse_mode is plain (default), markdown (**bold**, *italic*, __underline__, ~~strike~~, ||spoiler||, `code`, ```lang blocks```, [text](url), > quotes) or html (Telegram Bot API tags). Mention a user with a tg://user?id=<id> link. topic_id posts into a forum topic. schedule_date (RFC3339 or relative like \"in 2h\", \"in 1d\") schedules the message instead of sending it now (not available to bots); see list_scheduled.",
 	}, SendMessage(c))
 
-	mcp.AddTool(server, &mcp.Tool{
+	addTool(server, c, &mcp.Tool{
 		Name:        "reply_message",
-		Description: "Reply to a specific message in a chat",
+		Description: "Reply to a specific message in a chat. Supports the same parse_mode values as send_message. With comment=true, message_id is a channel post and the reply is posted as a comment in its discussion group, whose chat is returned. Supports schedule_date like send_message, except for bots.",
 	}, ReplyMessage(c))
 
-	mcp.AddTool(server, &mcp.Tool{
+	addTool(server, c, &mcp.Tool{
 		Name:        "forward_message",
 		Description: "Forward a message from one chat to another",
 	}, ForwardMessage(c))
+
+	addTool(server, c, &mcp.Tool{
+		Name:        "forward_messages",
+		Description: "Forward up to 100 messages from one chat to another in one request. drop_author sends them as copies without the forward header, drop_media_captions also removes captions, silent skips notifications and topic_id forwards into a forum topic. Returns the new message ID for each source ID.",
+	}, ForwardMessages(c))
 }
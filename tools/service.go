@@ -0,0 +1,109 @@
+package tools
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gotd/td/tg"
+)
+
+// Action describes what a service message records, such as a member joining
+// or the chat title changing. Users lists the members it concerns.
+type Action struct {
+	Type  string    `json:"type"`
+	Users []ChatRef `json:"users,omitempty"`
+	Title string    `json:"title,omitempty"`
+}
+
+// newServiceMessage converts a service message, using a short description of
+// the action as its text.
+func newServiceMessage(m *tg.MessageService, refs *peerRefs) Message {
+	msg := Message{
+		ID:    m.ID,
+		Date:  formatDate(m.Date),
+		IsOut: m.Out,
+	}
+	setSender(&msg, m.FromID, m.PeerID, m.Out, refs)
+
+	if header, ok := m.ReplyTo.(*tg.MessageReplyHeader); ok {
+		msg.ReplyTo = &ReplyTo{
+			MessageID: header.ReplyToMsgID,
+			TopID:     header.ReplyToTopID,
+		}
+	}
+
+	action := &Action{Type: typeName(m.Action.TypeName(), "messageAction")}
+	actor := msg.FromName
+	if actor == "" {
+		actor = "Someone"
+	}
+
+	switch a := m.Action.(type) {
+	case *tg.MessageActionChatCreate:
+		action.Title = a.Title
+		action.Users = userRefs(refs, a.Users)
+		msg.Text = fmt.Sprintf("%s created the group %q", actor, a.Title)
+	case *tg.MessageActionChannelCreate:
+		action.Title = a.Title
+		msg.Text = fmt.Sprintf("Channel %q created", a.Title)
+	case *tg.MessageActionChatEditTitle:
+		action.Title = a.Title
+		msg.Text = fmt.Sprintf("%s changed the title to %q", actor, a.Title)
+	case *tg.MessageActionChatEditPhoto:
+		msg.Text = fmt.Sprintf("%s changed the chat photo", actor)
+	case *tg.MessageActionChatDeletePhoto:
+		msg.Text = fmt.Sprintf("%s removed the chat photo", actor)
+	case *tg.MessageActionChatAddUser:
+		action.Users = userRefs(refs, a.Users)
+		if len(a.Users) == 1 && a.Users[0] == msg.FromID {
+			msg.Text = fmt.Sprintf("%s joined", actor)
+		} else {
+			msg.Text = fmt.Sprintf("%s added %s", actor, refTitles(action.Users))
+		}
+	case *tg.MessageActionChatJoinedByLink:
+		msg.Text = fmt.Sprintf("%s joined via invite link", actor)
+	case *tg.MessageActionChatJoinedByRequest:
+		msg.Text = fmt.Sprintf("%s joined after their request was approved", actor)
+	case *tg.MessageActionChatDeleteUser:
+		action.Users = userRefs(refs, []int64{a.UserID})
+		if a.UserID == msg.FromID {
+			msg.Text = fmt.Sprintf("%s left", actor)
+		} else {
+			msg.Text = fmt.Sprintf("%s removed %s", actor, refTitles(action.Users))
+		}
+	case *tg.MessageActionPinMessage:
+		msg.Text = fmt.Sprintf("%s pinned a message", actor)
+	case *tg.MessageActionChatMigrateTo:
+		msg.Text = "Group upgraded to a supergroup"
+	case *tg.MessageActionChannelMigrateFrom:
+		action.Title = a.Title
+		msg.Text = fmt.Sprintf("Supergroup created from the group %q", a.Title)
+	case *tg.MessageActionHistoryClear:
+		msg.Text = "History cleared"
+	default:
+		msg.Text = strings.ReplaceAll(action.Type, "_", " ")
+	}
+
+	msg.Action = action
+	return msg
+}
+
+func userRefs(refs *peerRefs, ids []int64) []ChatRef {
+	users := make([]ChatRef, 0, len(ids))
+	for _, id := range ids {
+		users = append(users, refs.user(id))
+	}
+	return users
+}
+
+func refTitles(refs []ChatRef) string {
+	titles := make([]string, 0, len(refs))
+	for _, r := range refs {
+		title := r.Title
+		if title == "" {
+			title = fmt.Sprintf("user %d", r.ID)
+		}
+		titles = append(titles, title)
+	}
+	return strings.Join(titles, ", ")
+}
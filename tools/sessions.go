@@ -0,0 +1,257 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgerr"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"tg-mcp/client"
+)
+
+// Session is a login of the account. Telegram reports the current session
+// with a zero hash.
+type Session struct {
+	Hash            int64  `json:"hash"`
+	Current         bool   `json:"current,omitempty"`
+	Device          string `json:"device,omitempty"`
+	Platform        string `json:"platform,omitempty"`
+	SystemVersion   string `json:"system_version,omitempty"`
+	App             string `json:"app,omitempty"`
+	AppVersion      string `json:"app_version,omitempty"`
+	OfficialApp     bool   `json:"official_app,omitempty"`
+	IP              string `json:"ip,omitempty"`
+	Location        string `json:"location,omitempty"`
+	Created         string `json:"created,omitempty"`
+	LastActive      string `json:"last_active,omitempty"`
+	PasswordPending bool   `json:"password_pending,omitempty"`
+	Unconfirmed     bool   `json:"unconfirmed,omitempty"`
+}
+
+func newSession(a tg.Authorization) Session {
+	var location []string
+	for _, part := range []string{a.Region, a.Country} {
+		if part != "" {
+			location = append(location, part)
+		}
+	}
+
+	return Session{
+		Hash:            a.Hash,
+		Current:         a.Current,
+		Device:          a.DeviceModel,
+		Platform:        a.Platform,
+		SystemVersion:   a.SystemVersion,
+		App:             a.AppName,
+		AppVersion:      a.AppVersion,
+		OfficialApp:     a.OfficialApp,
+		IP:              a.IP,
+		Location:        strings.Join(location, ", "),
+		Created:         formatDate(a.DateCreated),
+		LastActive:      formatDate(a.DateActive),
+		PasswordPending: a.PasswordPending,
+		Unconfirmed:     a.Unconfirmed,
+	}
+}
+
+type ListSessionsInput struct{}
+
+type ListSessionsOutput struct {
+	Success  bool      `json:"success"`
+	Sessions []Session `json:"sessions,omitempty"`
+	Message  string    `json:"message,omitempty"`
+}
+
+func ListSessions(c *client.Client) func(ctx context.Context, req *mcp.CallToolRequest, input ListSessionsInput) (*mcp.CallToolResult, ListSessionsOutput, error) {
+	return func(ctx context.Context, req *mcp.CallToolRequest, input ListSessionsInput) (*mcp.CallToolResult, ListSessionsOutput, error) {
+		if !c.IsAuthorized() {
+			return nil, ListSessionsOutput{
+				Success: false,
+				Message: "Not authorized",
+			}, nil
+		}
+
+		api := c.API()
+		if api == nil {
+			return nil, ListSessionsOutput{
+				Success: false,
+				Message: "Client is not running",
+			}, nil
+		}
+
+		sessions, err := api.AccountGetAuthorizations(ctx)
+		if err != nil {
+			return nil, ListSessionsOutput{
+				Success: false,
+				Message: fmt.Sprintf("Failed to get sessions: %v", err),
+			}, nil
+		}
+
+		output := ListSessionsOutput{Success: true}
+		for _, a := range sessions.Authorizations {
+			output.Sessions = append(output.Sessions, newSession(a))
+		}
+		return nil, output, nil
+	}
+}
+
+type TerminateSessionInput struct {
+	Hash int64 `json:"hash"`
+}
+
+type TerminateSessionOutput struct {
+	Success bool     `json:"success"`
+	Session *Session `json:"session,omitempty"`
+	Message string   `json:"message,omitempty"`
+}
+
+func TerminateSession(c *client.Client) func(ctx context.Context, req *mcp.CallToolRequest, input TerminateSessionInput) (*mcp.CallToolResult, TerminateSessionOutput, error) {
+	return func(ctx context.Context, req *mcp.CallToolRequest, input TerminateSessionInput) (*mcp.CallToolResult, TerminateSessionOutput, error) {
+		if !c.IsAuthorized() {
+			return nil, TerminateSessionOutput{
+				Success: false,
+				Message: "Not authorized",
+			}, nil
+		}
+
+		api := c.API()
+		if api == nil {
+			return nil, TerminateSessionOutput{
+				Success: false,
+				Message: "Client is not running",
+			}, nil
+		}
+
+		sessions, err := api.AccountGetAuthorizations(ctx)
+		if err != nil {
+			return nil, TerminateSessionOutput{
+				Success: false,
+				Message: fmt.Sprintf("Failed to get sessions: %v", err),
+			}, nil
+		}
+
+		var target *Session
+		for _, a := range sessions.Authorizations {
+			if a.Hash == input.Hash {
+				s := newSession(a)
+				target = &s
+				break
+			}
+		}
+		if target == nil {
+			return nil, TerminateSessionOutput{
+				Success: false,
+				Message: fmt.Sprintf("Session %d not found", input.Hash),
+			}, nil
+		}
+		if target.Current {
+			return nil, TerminateSessionOutput{
+				Success: false,
+				Session: target,
+				Message: "Refusing to terminate the current session; use auth_logout instead",
+			}, nil
+		}
+
+		if _, err := api.AccountResetAuthorization(ctx, input.Hash); err != nil {
+			return nil, TerminateSessionOutput{
+				Success: false,
+				Session: target,
+				Message: resetError(err),
+			}, nil
+		}
+
+		return nil, TerminateSessionOutput{
+			Success: true,
+			Session: target,
+			Message: "Session terminated",
+		}, nil
+	}
+}
+
+type TerminateOtherSessionsInput struct{}
+
+type TerminateOtherSessionsOutput struct {
+	Success    bool      `json:"success"`
+	Terminated []Session `json:"terminated,omitempty"`
+	Message    string    `json:"message,omitempty"`
+}
+
+func TerminateOtherSessions(c *client.Client) func(ctx context.Context, req *mcp.CallToolRequest, input TerminateOtherSessionsInput) (*mcp.CallToolResult, TerminateOtherSessionsOutput, error) {
+	return func(ctx context.Context, req *mcp.CallToolRequest, input TerminateOtherSessionsInput) (*mcp.CallToolResult, TerminateOtherSessionsOutput, error) {
+		if !c.IsAuthorized() {
+			return nil, TerminateOtherSessionsOutput{
+				Success: false,
+				Message: "Not authorized",
+			}, nil
+		}
+
+		api := c.API()
+		if api == nil {
+			return nil, TerminateOtherSessionsOutput{
+				Success: false,
+				Message: "Client is not running",
+			}, nil
+		}
+
+		sessions, err := api.AccountGetAuthorizations(ctx)
+		if err != nil {
+			return nil, TerminateOtherSessionsOutput{
+				Success: false,
+				Message: fmt.Sprintf("Failed to get sessions: %v", err),
+			}, nil
+		}
+
+		var others []Session
+		for _, a := range sessions.Authorizations {
+			if !a.Current {
+				others = append(others, newSession(a))
+			}
+		}
+		if len(others) == 0 {
+			return nil, TerminateOtherSessionsOutput{
+				Success: true,
+				Message: "No other sessions",
+			}, nil
+		}
+
+		if _, err := api.AuthResetAuthorizations(ctx); err != nil {
+			return nil, TerminateOtherSessionsOutput{
+				Success: false,
+				Message: resetError(err),
+			}, nil
+		}
+
+		return nil, TerminateOtherSessionsOutput{
+			Success:    true,
+			Terminated: others,
+			Message:    fmt.Sprintf("Terminated %d sessions", len(others)),
+		}, nil
+	}
+}
+
+func resetError(err error) string {
+	if tgerr.Is(err, "FRESH_RESET_AUTHORISATION_FORBIDDEN") {
+		return "Telegram does not allow a session less than 24 hours old to terminate others"
+	}
+	return fmt.Sprintf("Failed to terminate sessions: %v", err)
+}
+
+func RegisterSessionsTools(server *mcp.Server, c *client.Client) {
+	addUserTool(server, c, &mcp.Tool{
+		Name:        "list_sessions",
+		Description: "List the active logins of the account: device, app, IP, location and when each was created and last active. The session this server uses is marked current.",
+	}, ListSessions(c))
+
+	addUserTool(server, c, &mcp.Tool{
+		Name:        "terminate_session",
+		Description: "Log out another session by the hash from list_sessions. The current session cannot be terminated this way.",
+	}, TerminateSession(c))
+
+	addUserTool(server, c, &mcp.Tool{
+		Name:        "terminate_all_other_sessions",
+		Description: "Log out every session of the account except the one this server uses. Returns the sessions that were terminated.",
+	}, TerminateOtherSessions(c))
+}
@@ -0,0 +1,371 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gotd/td/tg"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"tg-mcp/client"
+)
+
+// generalTopicID is the forum's General topic, which takes messages sent
+// without a reply.
+const generalTopicID = 1
+
+// threadReply returns the message to reply to so that a message lands in
+// the given forum topic, preferring an explicit reply.
+func threadReply(replyTo, topicID int) int {
+	if replyTo != 0 {
+		return replyTo
+	}
+	if topicID > generalTopicID {
+		return topicID
+	}
+	return 0
+}
+
+type Topic struct {
+	ID          int    `json:"id"`
+	Title       string `json:"title"`
+	Date        string `json:"date"`
+	IconEmojiID int64  `json:"icon_emoji_id,omitempty"`
+	TopMessage  int    `json:"top_message,omitempty"`
+	UnreadCount int    `json:"unread_count,omitempty"`
+	Closed      bool   `json:"closed,omitempty"`
+	Pinned      bool   `json:"pinned,omitempty"`
+	Hidden      bool   `json:"hidden,omitempty"`
+}
+
+type ListTopicsInput struct {
+	Chat  string `json:"chat"`
+	Query string `json:"query,omitempty"`
+	Limit int    `json:"limit,omitempty"`
+}
+
+type ListTopicsOutput struct {
+	Success bool    `json:"success"`
+	Topics  []Topic `json:"topics,omitempty"`
+	Total   int     `json:"total,omitempty"`
+	Message string  `json:"message,omitempty"`
+}
+
+func ListTopics(c *client.Client) func(ctx context.Context, req *mcp.CallToolRequest, input ListTopicsInput) (*mcp.CallToolResult, ListTopicsOutput, error) {
+	return func(ctx context.Context, req *mcp.CallToolRequest, input ListTopicsInput) (*mcp.CallToolResult, ListTopicsOutput, error) {
+		if !c.IsAuthorized() {
+			return nil, ListTopicsOutput{
+				Success: false,
+				Message: "Not authorized",
+			}, nil
+		}
+
+		api := c.API()
+		if api == nil {
+			return nil, ListTopicsOutput{
+				Success: false,
+				Message: "Client is not running",
+			}, nil
+		}
+
+		limit := input.Limit
+		if limit <= 0 {
+			limit = 50
+		}
+		if limit > 100 {
+			limit = 100
+		}
+
+		peer, err := c.Resolve(ctx, input.Chat)
+		if err != nil {
+			return nil, ListTopicsOutput{
+				Success: false,
+				Message: fmt.Sprintf("Failed to resolve chat: %v", err),
+			}, nil
+		}
+		if peer.Kind != client.PeerChannel {
+			return nil, ListTopicsOutput{
+				Success: false,
+				Message: "Topics are only available in forum supergroups",
+			}, nil
+		}
+
+		resp, err := api.MessagesGetForumTopics(ctx, &tg.MessagesGetForumTopicsRequest{
+			Peer:  peer.InputPeer(),
+			Q:     input.Query,
+			Limit: limit,
+		})
+		if err != nil {
+			return nil, ListTopicsOutput{
+				Success: false,
+				Message: fmt.Sprintf("Failed to list topics: %v", err),
+			}, nil
+		}
+
+		topics := make([]Topic, 0, len(resp.Topics))
+		for _, t := range resp.Topics {
+			topic, ok := t.(*tg.ForumTopic)
+			if !ok {
+				continue
+			}
+			topics = append(topics, Topic{
+				ID:          topic.ID,
+				Title:       topic.Title,
+				Date:        formatDate(topic.Date),
+				IconEmojiID: topic.IconEmojiID,
+				TopMessage:  topic.TopMessage,
+				UnreadCount: topic.UnreadCount,
+				Closed:      topic.Closed,
+				Pinned:      topic.Pinned,
+				Hidden:      topic.Hidden,
+			})
+		}
+
+		return nil, ListTopicsOutput{
+			Success: true,
+			Topics:  topics,
+			Total:   resp.Count,
+		}, nil
+	}
+}
+
+type CreateTopicInput struct {
+	Chat        string `json:"chat"`
+	Title       string `json:"title"`
+	IconColor   int    `json:"icon_color,omitempty"`
+	IconEmojiID int64  `json:"icon_emoji_id,omitempty"`
+}
+
+type CreateTopicOutput struct {
+	Success bool   `json:"success"`
+	TopicID int    `json:"topic_id,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+func CreateTopic(c *client.Client) func(ctx context.Context, req *mcp.CallToolRequest, input CreateTopicInput) (*mcp.CallToolResult, CreateTopicOutput, error) {
+	return func(ctx context.Context, req *mcp.CallToolRequest, input CreateTopicInput) (*mcp.CallToolResult, CreateTopicOutput, error) {
+		if !c.IsAuthorized() {
+			return nil, CreateTopicOutput{
+				Success: false,
+				Message: "Not authorized",
+			}, nil
+		}
+
+		api := c.API()
+		if api == nil {
+			return nil, CreateTopicOutput{
+				Success: false,
+				Message: "Client is not running",
+			}, nil
+		}
+
+		if input.Title == "" {
+			return nil, CreateTopicOutput{
+				Success: false,
+				Message: "Title is required",
+			}, nil
+		}
+
+		peer, err := c.Resolve(ctx, input.Chat)
+		if err != nil {
+			return nil, CreateTopicOutput{
+				Success: false,
+				Message: fmt.Sprintf("Failed to resolve chat: %v", err),
+			}, nil
+		}
+		if peer.Kind != client.PeerChannel {
+			return nil, CreateTopicOutput{
+				Success: false,
+				Message: "Topics are only available in forum supergroups",
+			}, nil
+		}
+
+		randomID, err := client.RandomID()
+		if err != nil {
+			return nil, CreateTopicOutput{
+				Success: false,
+				Message: fmt.Sprintf("Failed to create topic: %v", err),
+			}, nil
+		}
+
+		updates, err := api.MessagesCreateForumTopic(ctx, &tg.MessagesCreateForumTopicRequest{
+			Peer:        peer.InputPeer(),
+			Title:       input.Title,
+			IconColor:   input.IconColor,
+			IconEmojiID: input.IconEmojiID,
+			RandomID:    randomID,
+		})
+		if err != nil {
+			return nil, CreateTopicOutput{
+				Success: false,
+				Message: fmt.Sprintf("Failed to create topic: %v", err),
+			}, nil
+		}
+
+		// A topic is identified by the service message that created it.
+		topicID := extractMessageID(updates)
+
+		return nil, CreateTopicOutput{
+			Success: true,
+			TopicID: topicID,
+			Message: fmt.Sprintf("Created topic: %s", input.Title),
+		}, nil
+	}
+}
+
+type EditTopicInput struct {
+	Chat        string `json:"chat"`
+	TopicID     int    `json:"topic_id"`
+	Title       string `json:"title,omitempty"`
+	IconEmojiID int64  `json:"icon_emoji_id,omitempty"`
+}
+
+type EditTopicOutput struct {
+	Success bool   `json:"success"`
+	Message string `json:"message,omitempty"`
+}
+
+func EditTopic(c *client.Client) func(ctx context.Context, req *mcp.CallToolRequest, input EditTopicInput) (*mcp.CallToolResult, EditTopicOutput, error) {
+	return func(ctx context.Context, req *mcp.CallToolRequest, input EditTopicInput) (*mcp.CallToolResult, EditTopicOutput, error) {
+		if !c.IsAuthorized() {
+			return nil, EditTopicOutput{
+				Success: false,
+				Message: "Not authorized",
+			}, nil
+		}
+
+		api := c.API()
+		if api == nil {
+			return nil, EditTopicOutput{
+				Success: false,
+				Message: "Client is not running",
+			}, nil
+		}
+
+		if input.Title == "" && input.IconEmojiID == 0 {
+			return nil, EditTopicOutput{
+				Success: false,
+				Message: "Nothing to change: provide title or icon_emoji_id",
+			}, nil
+		}
+
+		peer, err := c.Resolve(ctx, input.Chat)
+		if err != nil {
+			return nil, EditTopicOutput{
+				Success: false,
+				Message: fmt.Sprintf("Failed to resolve chat: %v", err),
+			}, nil
+		}
+		if peer.Kind != client.PeerChannel {
+			return nil, EditTopicOutput{
+				Success: false,
+				Message: "Topics are only available in forum supergroups",
+			}, nil
+		}
+
+		request := &tg.MessagesEditForumTopicRequest{
+			Peer:        peer.InputPeer(),
+			TopicID:     input.TopicID,
+			Title:       input.Title,
+			IconEmojiID: input.IconEmojiID,
+		}
+		if _, err := api.MessagesEditForumTopic(ctx, request); err != nil {
+			return nil, EditTopicOutput{
+				Success: false,
+				Message: fmt.Sprintf("Failed to edit topic: %v", err),
+			}, nil
+		}
+
+		return nil, EditTopicOutput{
+			Success: true,
+			Message: "Topic updated",
+		}, nil
+	}
+}
+
+type CloseTopicInput struct {
+	Chat    string `json:"chat"`
+	TopicID int    `json:"topic_id"`
+	Reopen  bool   `json:"reopen,omitempty"`
+}
+
+type CloseTopicOutput struct {
+	Success bool   `json:"success"`
+	Message string `json:"message,omitempty"`
+}
+
+func CloseTopic(c *client.Client) func(ctx context.Context, req *mcp.CallToolRequest, input CloseTopicInput) (*mcp.CallToolResult, CloseTopicOutput, error) {
+	return func(ctx context.Context, req *mcp.CallToolRequest, input CloseTopicInput) (*mcp.CallToolResult, CloseTopicOutput, error) {
+		if !c.IsAuthorized() {
+			return nil, CloseTopicOutput{
+				Success: false,
+				Message: "Not authorized",
+			}, nil
+		}
+
+		api := c.API()
+		if api == nil {
+			return nil, CloseTopicOutput{
+				Success: false,
+				Message: "Client is not running",
+			}, nil
+		}
+
+		peer, err := c.Resolve(ctx, input.Chat)
+		if err != nil {
+			return nil, CloseTopicOutput{
+				Success: false,
+				Message: fmt.Sprintf("Failed to resolve chat: %v", err),
+			}, nil
+		}
+		if peer.Kind != client.PeerChannel {
+			return nil, CloseTopicOutput{
+				Success: false,
+				Message: "Topics are only available in forum supergroups",
+			}, nil
+		}
+
+		request := &tg.MessagesEditForumTopicRequest{
+			Peer:    peer.InputPeer(),
+			TopicID: input.TopicID,
+		}
+		request.SetClosed(!input.Reopen)
+		if _, err := api.MessagesEditForumTopic(ctx, request); err != nil {
+			return nil, CloseTopicOutput{
+				Success: false,
+				Message: fmt.Sprintf("Failed to edit topic: %v", err),
+			}, nil
+		}
+
+		message := "Topic closed"
+		if input.Reopen {
+			message = "Topic reopened"
+		}
+		return nil, CloseTopicOutput{
+			Success: true,
+			Message: message,
+		}, nil
+	}
+}
+
+func RegisterTopicsTools(server *mcp.Server, c *client.Client) {
+	addTool(server, c, &mcp.Tool{
+		Name:        "list_topics",
+		Description: "List the topics of a forum supergroup, optionally filtered by query (up to 100). Pass a topic's id as topic_id to get_messages, get_history, search_messages, send_message, send_file or send_album to work inside it.",
+	}, ListTopics(c))
+
+	addTool(server, c, &mcp.Tool{
+		Name:        "create_topic",
+		Description: "Create a topic in a forum supergroup. Optional icon_color (RGB integer) and icon_emoji_id (custom emoji document ID). Returns the new topic_id.",
+	}, CreateTopic(c))
+
+	addTool(server, c, &mcp.Tool{
+		Name:        "edit_topic",
+		Description: "Rename a forum topic or change its icon_emoji_id.",
+	}, EditTopic(c))
+
+	addTool(server, c, &mcp.Tool{
+		Name:        "close_topic",
+		Description: "Close a forum topic so only admins can post in it, or reopen it with reopen=true.",
+	}, CloseTopic(c))
+}
@@ -0,0 +1,166 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gotd/td/tg"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"tg-mcp/client"
+	"tg-mcp/format"
+)
+
+type UpdateNotification struct {
+	Event      string   `json:"event"`
+	Chat       *ChatRef `json:"chat,omitempty"`
+	Message    *Message `json:"message,omitempty"`
+	MessageIDs []int    `json:"message_ids,omitempty"`
+}
+
+func forwardUpdates(server *mcp.Server, c *client.Client) {
+	events, _ := c.Subscribe()
+	for e := range events {
+		n := UpdateNotification{
+			Event:      e.Kind,
+			MessageIDs: e.MessageIDs,
+		}
+		if e.Peer.Kind != "" {
+			ref := chatRef(e.Peer)
+			n.Chat = &ref
+		}
+		if msg, ok := convertMessage(e.Message, entityRefs(c, e.Entities), format.ModePlain); ok {
+			n.Message = &msg
+		}
+
+		// Sessions that have not called logging/setLevel get nothing; the
+		// SDK drops log messages until a level is set.
+		for ss := range server.Sessions() {
+			_ = ss.Log(context.Background(), &mcp.LoggingMessageParams{
+				Level:  "info",
+				Logger: "telegram",
+				Data:   n,
+			})
+		}
+	}
+}
+
+type WaitForMessagesInput struct {
+	Chat            string `json:"chat,omitempty"`
+	Contains        string `json:"contains,omitempty"`
+	IncludeOutgoing bool   `json:"include_outgoing,omitempty"`
+	Timeout         int    `json:"timeout,omitempty"`
+}
+
+type WaitForMessagesOutput struct {
+	Success  bool          `json:"success"`
+	Messages []ChatMessage `json:"messages,omitempty"`
+	TimedOut bool          `json:"timed_out"`
+	Message  string        `json:"message,omitempty"`
+}
+
+func WaitForMessages(c *client.Client) func(ctx context.Context, req *mcp.CallToolRequest, input WaitForMessagesInput) (*mcp.CallToolResult, WaitForMessagesOutput, error) {
+	return func(ctx context.Context, req *mcp.CallToolRequest, input WaitForMessagesInput) (*mcp.CallToolResult, WaitForMessagesOutput, error) {
+		if !c.IsAuthorized() {
+			return nil, WaitForMessagesOutput{
+				Success: false,
+				Message: "Not authorized",
+			}, nil
+		}
+
+		timeout := input.Timeout
+		if timeout <= 0 {
+			timeout = 30
+		}
+		if timeout > 300 {
+			timeout = 300
+		}
+
+		events, unsubscribe := c.Subscribe()
+		defer unsubscribe()
+
+		var chat *client.Peer
+		if input.Chat != "" {
+			peer, err := c.Resolve(ctx, input.Chat)
+			if err != nil {
+				return nil, WaitForMessagesOutput{
+					Success: false,
+					Message: fmt.Sprintf("Failed to resolve chat: %v", err),
+				}, nil
+			}
+			chat = &peer
+		}
+
+		match := func(e client.Event) (ChatMessage, bool) {
+			if e.Kind != client.EventNewMessage {
+				return ChatMessage{}, false
+			}
+			m, ok := e.Message.(*tg.Message)
+			if !ok {
+				return ChatMessage{}, false
+			}
+			if m.Out && !input.IncludeOutgoing {
+				return ChatMessage{}, false
+			}
+			if chat != nil && (e.Peer.Kind != chat.Kind || e.Peer.ID != chat.ID) {
+				return ChatMessage{}, false
+			}
+			if input.Contains != "" && !strings.Contains(strings.ToLower(m.Message), strings.ToLower(input.Contains)) {
+				return ChatMessage{}, false
+			}
+			return ChatMessage{
+				Chat:    chatRef(e.Peer),
+				Message: newMessage(m, entityRefs(c, e.Entities), format.ModePlain),
+			}, true
+		}
+
+		timer := time.NewTimer(time.Duration(timeout) * time.Second)
+		defer timer.Stop()
+
+		var result []ChatMessage
+		for len(result) == 0 {
+			select {
+			case e := <-events:
+				if msg, ok := match(e); ok {
+					result = append(result, msg)
+				}
+			case <-timer.C:
+				return nil, WaitForMessagesOutput{
+					Success:  true,
+					TimedOut: true,
+				}, nil
+			case <-ctx.Done():
+				return nil, WaitForMessagesOutput{
+					Success: false,
+					Message: ctx.Err().Error(),
+				}, nil
+			}
+		}
+
+		// Pick up anything that arrived in the same burst.
+		for {
+			select {
+			case e := <-events:
+				if msg, ok := match(e); ok {
+					result = append(result, msg)
+				}
+			default:
+				return nil, WaitForMessagesOutput{
+					Success:  true,
+					Messages: result,
+				}, nil
+			}
+		}
+	}
+}
+
+func RegisterUpdatesTools(server *mcp.Server, c *client.Client) {
+	go forwardUpdates(server, c)
+
+	addTool(server, c, &mcp.Tool{
+		Name:        "wait_for_messages",
+		Description: "Block until a new incoming message arrives. Optionally filter by chat and text (contains, case-insensitive). Timeout in seconds (default 30, max 300). New, edited and deleted messages are also pushed as logging notifications from the \"telegram\" logger at level info, but only after the client has called logging/setLevel with info or debug.",
+	}, WaitForMessages(c))
+}
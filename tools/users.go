@@ -3,8 +3,6 @@ package tools
 import (
 	"context"
 	"fmt"
-	"strconv"
-	"strings"
 
 	"github.com/gotd/td/tg"
 	"github.com/modelcontextprotocol/go-sdk/mcp"
@@ -52,69 +50,44 @@ func GetUser(c *client.Client) func(ctx context.Context, req *mcp.CallToolReques
 			}, nil
 		}
 
-		var user *tg.User
-		var inputUser tg.InputUserClass
-
-		if userID, err := strconv.ParseInt(input.User, 10, 64); err == nil {
-			dialogs, err := api.MessagesGetDialogs(ctx, &tg.MessagesGetDialogsRequest{
-				OffsetPeer: &tg.InputPeerEmpty{},
-				Limit:      200,
-			})
-			if err != nil {
-				return nil, GetUserOutput{
-					Success: false,
-					Message: fmt.Sprintf("Failed to get dialogs: %v", err),
-				}, nil
-			}
-
-			var users []tg.UserClass
-			switch d := dialogs.(type) {
-			case *tg.MessagesDialogs:
-				users = d.Users
-			case *tg.MessagesDialogsSlice:
-				users = d.Users
-			}
-
-			for _, u := range users {
-				if usr, ok := u.(*tg.User); ok && usr.ID == userID {
-					user = usr
-					inputUser = &tg.InputUser{UserID: usr.ID, AccessHash: usr.AccessHash}
-					break
-				}
-			}
+		inputUser, err := c.ResolveUser(ctx, input.User)
+		if err != nil {
+			return nil, GetUserOutput{
+				Success: false,
+				Message: fmt.Sprintf("Failed to find user: %v", err),
+			}, nil
+		}
 
-			if user == nil {
-				return nil, GetUserOutput{
-					Success: false,
-					Message: fmt.Sprintf("User %d not found in dialogs", userID),
-				}, nil
-			}
+		// The full profile only adds the bio, so fall back to the basic
+		// user when Telegram refuses it.
+		var users []tg.UserClass
+		var bio string
+		if fullUser, err := api.UsersGetFullUser(ctx, inputUser); err == nil {
+			users = fullUser.Users
+			bio = fullUser.FullUser.About
 		} else {
-			username := strings.TrimPrefix(input.User, "@")
-			resolved, err := api.ContactsResolveUsername(ctx, &tg.ContactsResolveUsernameRequest{
-				Username: username,
-			})
+			users, err = api.UsersGetUsers(ctx, []tg.InputUserClass{inputUser})
 			if err != nil {
 				return nil, GetUserOutput{
 					Success: false,
-					Message: fmt.Sprintf("Failed to resolve username: %v", err),
+					Message: fmt.Sprintf("Failed to get user: %v", err),
 				}, nil
 			}
+		}
 
-			for _, u := range resolved.Users {
-				if usr, ok := u.(*tg.User); ok {
-					user = usr
-					inputUser = &tg.InputUser{UserID: usr.ID, AccessHash: usr.AccessHash}
-					break
-				}
+		var user *tg.User
+		for _, u := range users {
+			if usr, ok := u.(*tg.User); ok && usr.ID == inputUser.UserID {
+				user = usr
+				break
 			}
+		}
 
-			if user == nil {
-				return nil, GetUserOutput{
-					Success: false,
-					Message: "User not found",
-				}, nil
-			}
+		if user == nil {
+			return nil, GetUserOutput{
+				Success: false,
+				Message: "User not found",
+			}, nil
 		}
 
 		profile := UserProfile{
@@ -126,6 +99,7 @@ func GetUser(c *client.Client) func(ctx context.Context, req *mcp.CallToolReques
 			Bot:       user.Bot,
 			Verified:  user.Verified,
 			Premium:   user.Premium,
+			Bio:       bio,
 		}
 
 		if user.Status != nil {
@@ -143,13 +117,6 @@ func GetUser(c *client.Client) func(ctx context.Context, req *mcp.CallToolReques
 			}
 		}
 
-		if inputUser != nil {
-			fullUser, err := api.UsersGetFullUser(ctx, inputUser)
-			if err == nil && fullUser != nil {
-				profile.Bio = fullUser.FullUser.About
-			}
-		}
-
 		return nil, GetUserOutput{
 			Success: true,
 			User:    profile,
@@ -158,7 +125,7 @@ func GetUser(c *client.Client) func(ctx context.Context, req *mcp.CallToolReques
 }
 
 func RegisterUsersTools(server *mcp.Server, c *client.Client) {
-	mcp.AddTool(server, &mcp.Tool{
+	addTool(server, c, &mcp.Tool{
 		Name:        "get_user",
 		Description: "Get user profile information by username or ID",
 	}, GetUser(c))